@@ -27,11 +27,10 @@ func AllDigits() functional.Generator {
 
 // Return the nth digit of 1234567891011121314151617....
 func Digit(posit int) string {
-  g := AllDigits()
-  s := functional.Slice(g, posit, -1)
+  s := functional.Slice(AllDigits(), posit, -1)
+  defer s.Close()
   var r rune
   s.Next(&r)
-  g.Close()
   return string(r)
 }
 
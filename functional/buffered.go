@@ -0,0 +1,114 @@
+package functional
+
+import (
+  "io"
+  "sync"
+)
+
+// Buffered returns a Generator of T that emits the values of s, a Stream of
+// T, reading up to n values ahead of the caller on a separate goroutine so
+// that a slow s and a slow caller can work at the same time. The values read
+// ahead are stored in n values that Buffered creates up front with creater,
+// a Creater of T. copier, a Copier of T, copies each value to the caller; if
+// copier is nil, regular assignment is used. s must not be used elsewhere
+// until the returned Generator is exhausted or closed. Closing the returned
+// Generator stops the read ahead. It closes s, if s is an io.Closer, without
+// waiting for the read ahead's Next call on s to return and then waits for
+// it, so s must allow concurrent Close as described for Generator. If s is
+// an ErrStream, the returned Generator's Err method reports its error once
+// the values run out. If n < 1, 1 is used.
+func Buffered(s Stream, n int, creater Creater, copier Copier) Generator {
+  if n < 1 {
+    n = 1
+  }
+  if copier == nil {
+    copier = assignCopier
+  }
+  b := &bufferedGenerator{
+      s: s,
+      copier: copier,
+      free: make(chan interface{}, n),
+      filled: make(chan interface{}, n),
+      done: make(chan struct{}),
+      readerDone: make(chan struct{})}
+  for i := 0; i < n; i++ {
+    b.free <- creater()
+  }
+  go b.readAhead()
+  return b
+}
+
+type bufferedGenerator struct {
+  s Stream
+  copier Copier
+  // free holds the values ready to be read into.
+  free chan interface{}
+  // filled holds the values read from s in order. The reader closes
+  // filled when s is exhausted.
+  filled chan interface{}
+  done chan struct{}
+  readerDone chan struct{}
+  closeOnce sync.Once
+  closeErr error
+  // exhausted is true once Next has seen filled closed.
+  exhausted bool
+  err error
+}
+
+func (b *bufferedGenerator) Next(ptr interface{}) bool {
+  select {
+  case <-b.done:
+    return false
+  default:
+  }
+  select {
+  case <-b.done:
+    return false
+  case value, ok := <-b.filled:
+    if !ok {
+      b.exhausted = true
+      return false
+    }
+    b.copier(value, ptr)
+    b.free <- value
+    return true
+  }
+}
+
+func (b *bufferedGenerator) Err() error {
+  if !b.exhausted {
+    return nil
+  }
+  return b.err
+}
+
+func (b *bufferedGenerator) Close() error {
+  b.closeOnce.Do(func() {
+    close(b.done)
+    if c, ok := b.s.(io.Closer); ok {
+      b.closeErr = c.Close()
+    }
+    <-b.readerDone
+  })
+  return b.closeErr
+}
+
+func (b *bufferedGenerator) readAhead() {
+  defer close(b.readerDone)
+  defer close(b.filled)
+  for {
+    var value interface{}
+    select {
+    case <-b.done:
+      return
+    case value = <-b.free:
+    }
+    if !b.s.Next(value) {
+      if es, ok := b.s.(ErrStream); ok {
+        b.err = es.Err()
+      }
+      return
+    }
+    b.filled <- value
+  }
+}
@@ -0,0 +1,117 @@
+package functional
+
+import (
+    "fmt"
+    "strings"
+    "testing"
+    "testing/iotest"
+    "time"
+)
+
+func TestBuffered(t *testing.T) {
+  g := Buffered(xrange(0, 100), 8, func() interface{} { return new(int) }, nil)
+  var results []int
+  AppendValues(g, &results)
+  if len(results) != 100 || results[0] != 0 || results[99] != 99 {
+    t.Errorf("Expected 0 through 99 got %v", results)
+  }
+  if err := g.(ErrStream).Err(); err != nil {
+    t.Errorf("Expected no error got %v", err)
+  }
+  if err := g.Close(); err != nil {
+    t.Errorf("Expected no error got %v", err)
+  }
+}
+
+func TestBufferedReadsAhead(t *testing.T) {
+  source := &countingGenerator{}
+  g := Buffered(source, 4, func() interface{} { return new(int) }, nil)
+  var x int
+  if !g.Next(&x) || x != 0 {
+    t.Errorf("Expected 0 got %v", x)
+  }
+  // One value taken, so the reader can fill 4 more values.
+  for source.count() < 5 {
+  }
+  g.Close()
+  if n := source.count(); n != 5 {
+    t.Errorf("Expected 5 values read got %v", n)
+  }
+  if !source.isClosed() {
+    t.Error("Expected Close to close source.")
+  }
+  if g.Next(&x) {
+    t.Error("Expected closed Generator to be empty.")
+  }
+}
+
+func TestBufferedCopier(t *testing.T) {
+  s := NewStreamFromValues([][]int{{1, 2}, {3}, {4, 5, 6}})
+  creater := func() interface{} { return new([]int) }
+  copier := func(src, dest interface{}) {
+    *dest.(*[]int) = append([]int(nil), *src.(*[]int)...)
+  }
+  var results [][]int
+  AppendValues(Buffered(s, 2, creater, copier), &results)
+  if output := fmt.Sprintf("%v", results); output != "[[1 2] [3] [4 5 6]]" {
+    t.Errorf("Expected [[1 2] [3] [4 5 6]] got %v", output)
+  }
+}
+
+func TestBufferedError(t *testing.T) {
+  s := ReadLines(iotest.TimeoutReader(iotest.OneByteReader(strings.NewReader("a\nb\n"))))
+  g := Buffered(s, 3, func() interface{} { return new(string) }, nil)
+  defer g.Close()
+  var results []string
+  AppendValues(g, &results)
+  if output := strings.Join(results, ","); output != "a" {
+    t.Errorf("Expected 'a' got '%v'", output)
+  }
+  if err := g.(ErrStream).Err(); err != iotest.ErrTimeout {
+    t.Errorf("Expected timeout error got %v", err)
+  }
+}
+
+func TestBufferedCloseWhileWaiting(t *testing.T) {
+  name := writeTempFile(t, []byte("a\n"))
+  clock := newFakeClock()
+  source, err := FollowLines(name, &FollowOptions{Interval: time.Second, Clock: clock})
+  if err != nil {
+    t.Fatalf("Got error %v", err)
+  }
+  g := Buffered(source, 2, func() interface{} { return new(string) }, nil)
+  var line string
+  if !g.Next(&line) || line != "a" {
+    t.Errorf("Expected 'a' got '%v'", line)
+  }
+  // The read ahead waits for more lines.
+  clock.WaitForWaiters(1)
+  if err := g.Close(); err != nil {
+    t.Errorf("Expected no error got %v", err)
+  }
+  if g.Next(&line) {
+    t.Error("Expected closed Generator to be empty.")
+  }
+}
+
+func TestBufferedCloseGenerator(t *testing.T) {
+  g := Buffered(countingEmitter(), 1, func() interface{} { return new(int) }, nil)
+  var results []int
+  AppendValues(Slice(g, 0, 3), &results)
+  if output := fmt.Sprintf("%v", results); output != "[0 1 2]" {
+    t.Errorf("Expected [0 1 2] got %v", output)
+  }
+  g.Close()
+}
+
+func TestBufferedCloseWhileGeneratorWaits(t *testing.T) {
+  values := make(chan int)
+  asked := make(chan struct{}, 10)
+  g := Buffered(chanEmitter(values, asked), 2, func() interface{} { return new(int) }, nil)
+  // The read ahead waits for a value.
+  <-asked
+  if err := g.Close(); err != nil {
+    t.Errorf("Expected no error got %v", err)
+  }
+  close(values)
+}
@@ -0,0 +1,37 @@
+package functional
+
+import (
+  "time"
+)
+
+// Clock tells the time and waits for time to pass. Functions that wait
+// accept a Clock so that tests can substitute a fake one that runs
+// deterministically without real sleeps.
+type Clock interface {
+  // Now returns the current time.
+  Now() time.Time
+  // After returns a channel that receives the current time once d has
+  // elapsed.
+  After(d time.Duration) <-chan time.Time
+}
+
+// SystemClock is the Clock that uses the system time.
+var SystemClock Clock = systemClock{}
+
+type systemClock struct {
+}
+
+func (c systemClock) Now() time.Time {
+  return time.Now()
+}
+
+func (c systemClock) After(d time.Duration) <-chan time.Time {
+  return time.After(d)
+}
+
+func clockOrDefault(c Clock) Clock {
+  if c == nil {
+    return SystemClock
+  }
+  return c
+}
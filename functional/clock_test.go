@@ -0,0 +1,97 @@
+package functional
+
+import (
+    "sync"
+    "testing"
+    "time"
+)
+
+func TestSystemClock(t *testing.T) {
+  start := SystemClock.Now()
+  <-SystemClock.After(time.Millisecond)
+  if SystemClock.Now().Sub(start) < time.Millisecond {
+    t.Error("Expected After to wait.")
+  }
+}
+
+func TestFakeClock(t *testing.T) {
+  c := newFakeClock()
+  start := c.Now()
+  ch := c.After(2 * time.Second)
+  c.WaitForWaiters(1)
+  c.Advance(time.Second)
+  select {
+  case <-ch:
+    t.Error("After fired too soon.")
+  default:
+  }
+  c.Advance(time.Second)
+  if fired := <-ch; fired.Sub(start) != 2 * time.Second {
+    t.Errorf("Expected 2s got %v", fired.Sub(start))
+  }
+}
+
+// fakeClock is a Clock whose time moves only when the test advances it.
+type fakeClock struct {
+  mu sync.Mutex
+  cond *sync.Cond
+  now time.Time
+  waiters []fakeWaiter
+}
+
+type fakeWaiter struct {
+  when time.Time
+  ch chan time.Time
+}
+
+func newFakeClock() *fakeClock {
+  result := &fakeClock{now: time.Date(2012, 1, 1, 0, 0, 0, 0, time.UTC)}
+  result.cond = sync.NewCond(&result.mu)
+  return result
+}
+
+func (c *fakeClock) Now() time.Time {
+  c.mu.Lock()
+  defer c.mu.Unlock()
+  return c.now
+}
+
+func (c *fakeClock) After(d time.Duration) <-chan time.Time {
+  c.mu.Lock()
+  defer c.mu.Unlock()
+  ch := make(chan time.Time, 1)
+  if d <= 0 {
+    ch <- c.now
+    return ch
+  }
+  c.waiters = append(c.waiters, fakeWaiter{c.now.Add(d), ch})
+  c.cond.Broadcast()
+  return ch
+}
+
+// Advance moves this clock forward by d firing any channels that After
+// returned that are due.
+func (c *fakeClock) Advance(d time.Duration) {
+  c.mu.Lock()
+  defer c.mu.Unlock()
+  c.now = c.now.Add(d)
+  var pending []fakeWaiter
+  for _, w := range c.waiters {
+    if w.when.After(c.now) {
+      pending = append(pending, w)
+    } else {
+      w.ch <- c.now
+    }
+  }
+  c.waiters = pending
+}
+
+// WaitForWaiters blocks until at least n channels that After returned are
+// waiting to fire.
+func (c *fakeClock) WaitForWaiters(n int) {
+  c.mu.Lock()
+  defer c.mu.Unlock()
+  for len(c.waiters) < n {
+    c.cond.Wait()
+  }
+}
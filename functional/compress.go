@@ -0,0 +1,131 @@
+package functional
+
+import (
+  "bufio"
+  "bytes"
+  "compress/bzip2"
+  "compress/gzip"
+  "compress/zlib"
+  "io"
+  "os"
+)
+
+const (
+  // kProbeSize is how many bytes Decompress examines to detect zlib.
+  kProbeSize = 512
+)
+
+var (
+  kGzipMagic = []byte{0x1f, 0x8b}
+  kBzip2Magic = []byte("BZh")
+)
+
+// Decompress returns the contents of r. If r is compressed with gzip, bzip2
+// or zlib, the returned io.ReadCloser decompresses it on the fly; otherwise
+// it returns the contents of r as is. The compression format is determined
+// from the first few bytes of r. Closing the returned io.ReadCloser does not
+// close r.
+func Decompress(r io.Reader) (io.ReadCloser, error) {
+  br := bufio.NewReader(r)
+  magic, err := br.Peek(kProbeSize)
+  if err != nil && err != io.EOF {
+    return nil, err
+  }
+  switch {
+  case bytes.HasPrefix(magic, kGzipMagic):
+    return gzip.NewReader(br)
+  case isBzip2Header(magic):
+    return io.NopCloser(bzip2.NewReader(br)), nil
+  case isZlibHeader(magic) && inflates(magic):
+    return zlib.NewReader(br)
+  }
+  return io.NopCloser(br), nil
+}
+
+// OpenFile opens the named file and returns a Generator that emits the
+// values of the Stream f creates from the file's contents. If the file is
+// compressed with gzip, bzip2 or zlib, f sees the decompressed contents.
+// For example, OpenFile(name, func(r io.Reader) Stream { return ReadLines(r) })
+// returns a Generator of the lines in a possibly compressed file. The
+// returned Generator closes the file when it is closed or exhausted. If the
+// Stream f returns implements ErrStream, the returned Generator does too.
+func OpenFile(name string, f func(r io.Reader) Stream) (Generator, error) {
+  file, err := os.Open(name)
+  if err != nil {
+    return nil, err
+  }
+  r, err := Decompress(file)
+  if err != nil {
+    file.Close()
+    return nil, err
+  }
+  return &fileGenerator{s: f(r), r: r, file: file}, nil
+}
+
+type fileGenerator struct {
+  s Stream
+  r io.Closer
+  file io.Closer
+  closed bool
+}
+
+func (g *fileGenerator) Next(ptr interface{}) bool {
+  if g.closed {
+    return false
+  }
+  if g.s.Next(ptr) {
+    return true
+  }
+  g.Close()
+  return false
+}
+
+func (g *fileGenerator) Err() error {
+  if es, ok := g.s.(ErrStream); ok {
+    return es.Err()
+  }
+  return nil
+}
+
+func (g *fileGenerator) Close() error {
+  if g.closed {
+    return nil
+  }
+  g.closed = true
+  err := g.r.Close()
+  if ferr := g.file.Close(); err == nil {
+    err = ferr
+  }
+  return err
+}
+
+// isBzip2Header returns true if b starts with a bzip2 header which is
+// "BZh" followed by the block size from '1' to '9'.
+func isBzip2Header(b []byte) bool {
+  if len(b) <= len(kBzip2Magic) || !bytes.HasPrefix(b, kBzip2Magic) {
+    return false
+  }
+  blockSize := b[len(kBzip2Magic)]
+  return blockSize >= '1' && blockSize <= '9'
+}
+
+// inflates returns true if b, the start of some zlib data, decompresses
+// without error. Since plain text can pass isZlibHeader, Decompress calls
+// inflates before treating data as zlib.
+func inflates(b []byte) bool {
+  r, err := zlib.NewReader(bytes.NewReader(b))
+  if err != nil {
+    return false
+  }
+  _, err = io.Copy(io.Discard, r)
+  return err == nil || err == io.ErrUnexpectedEOF
+}
+
+// isZlibHeader returns true if b starts with a zlib header using the
+// deflate method and no preset dictionary.
+func isZlibHeader(b []byte) bool {
+  if len(b) < 2 {
+    return false
+  }
+  return b[0] == 0x78 && b[1] & 0x20 == 0 && (int(b[0]) << 8 | int(b[1])) % 31 == 0
+}
@@ -0,0 +1,155 @@
+package functional
+
+import (
+    "bytes"
+    "compress/gzip"
+    "compress/zlib"
+    "io"
+    "os"
+    "path/filepath"
+    "strings"
+    "testing"
+)
+
+var (
+  // kBzip2Lines is "line one\nline two\n" compressed with bzip2.
+  kBzip2Lines = []byte("\x42\x5a\x68\x39\x31\x41\x59\x26\x53\x59\x8c\x77\xbf\xde\x00\x00\x04\xd1\x80\x00\x10\x40\x00\x02\x25\x84\x80\x20\x00\x31\x06\x4c\x40\xc8\x69\xa6\x8f\x0b\x2c\x20\x98\x9c\x27\x8b\xb9\x22\x9c\x28\x48\x46\x3b\xdf\xef\x00")
+)
+
+func TestOpenFilePlain(t *testing.T) {
+  name := writeTempFile(t, []byte("line one\nline two\n"))
+  verifyOpenFileLines(t, name)
+}
+
+func TestOpenFileGzip(t *testing.T) {
+  var b bytes.Buffer
+  w := gzip.NewWriter(&b)
+  io.WriteString(w, "line one\nline two\n")
+  w.Close()
+  verifyOpenFileLines(t, writeTempFile(t, b.Bytes()))
+}
+
+func TestOpenFileZlib(t *testing.T) {
+  var b bytes.Buffer
+  w := zlib.NewWriter(&b)
+  io.WriteString(w, "line one\nline two\n")
+  w.Close()
+  verifyOpenFileLines(t, writeTempFile(t, b.Bytes()))
+}
+
+func TestOpenFileLooksLikeZlib(t *testing.T) {
+  contents := "x^2 + y^2\nsecond line\n"
+  if !isZlibHeader([]byte(contents)) {
+    t.Fatal("Expected text to pass the zlib header check.")
+  }
+  g, err := OpenFile(writeTempFile(t, []byte(contents)), readLines)
+  if err != nil {
+    t.Fatalf("Got error %v", err)
+  }
+  var results []string
+  AppendValues(g, &results)
+  if output := strings.Join(results, ","); output != "x^2 + y^2,second line" {
+    t.Errorf("Expected 'x^2 + y^2,second line' got '%v'", output)
+  }
+  if g.(ErrStream).Err() != nil {
+    t.Errorf("Expected no error got %v", g.(ErrStream).Err())
+  }
+}
+
+func TestOpenFileLooksLikeBzip2(t *testing.T) {
+  g, err := OpenFile(writeTempFile(t, []byte("BZh.\n")), readLines)
+  if err != nil {
+    t.Fatalf("Got error %v", err)
+  }
+  var results []string
+  AppendValues(g, &results)
+  if output := strings.Join(results, ","); output != "BZh." {
+    t.Errorf("Expected 'BZh.' got '%v'", output)
+  }
+}
+
+func TestOpenFileBzip2(t *testing.T) {
+  verifyOpenFileLines(t, writeTempFile(t, kBzip2Lines))
+}
+
+func TestOpenFileEmpty(t *testing.T) {
+  g, err := OpenFile(writeTempFile(t, nil), readLines)
+  if err != nil {
+    t.Fatalf("Got error %v", err)
+  }
+  if g.Next(new(string)) {
+    t.Error("Expected empty Generator.")
+  }
+}
+
+func TestOpenFileCorrupt(t *testing.T) {
+  var b bytes.Buffer
+  w := gzip.NewWriter(&b)
+  io.WriteString(w, strings.Repeat("line\n", 1000))
+  w.Close()
+  g, err := OpenFile(writeTempFile(t, b.Bytes()[:b.Len() / 2]), readLines)
+  if err != nil {
+    t.Fatalf("Got error %v", err)
+  }
+  var results []string
+  AppendValues(g, &results)
+  if g.(ErrStream).Err() != io.ErrUnexpectedEOF {
+    t.Errorf("Expected io.ErrUnexpectedEOF got %v", g.(ErrStream).Err())
+  }
+}
+
+func TestOpenFileCloseEarly(t *testing.T) {
+  g, err := OpenFile(writeTempFile(t, []byte("line one\nline two\n")), readLines)
+  if err != nil {
+    t.Fatalf("Got error %v", err)
+  }
+  var line string
+  if !g.Next(&line) || line != "line one" {
+    t.Errorf("Expected 'line one' got '%v'", line)
+  }
+  if err := g.Close(); err != nil {
+    t.Errorf("Expected no error on close got %v", err)
+  }
+  if err := g.Close(); err != nil {
+    t.Errorf("Expected closing twice to do nothing got %v", err)
+  }
+  if g.Next(&line) {
+    t.Error("Expected closed Generator to be empty.")
+  }
+}
+
+func TestOpenFileMissing(t *testing.T) {
+  if _, err := OpenFile(filepath.Join(t.TempDir(), "missing"), readLines); err == nil {
+    t.Error("Expected error opening missing file.")
+  }
+}
+
+func verifyOpenFileLines(t *testing.T, name string) {
+  g, err := OpenFile(name, readLines)
+  if err != nil {
+    t.Fatalf("Got error %v", err)
+  }
+  var results []string
+  AppendValues(g, &results)
+  if output := strings.Join(results, ","); output != "line one,line two" {
+    t.Errorf("Expected 'line one,line two' got '%v'", output)
+  }
+  if g.(ErrStream).Err() != nil {
+    t.Errorf("Expected no error got %v", g.(ErrStream).Err())
+  }
+  if !g.(*fileGenerator).closed {
+    t.Error("Exhausting Generator should close file.")
+  }
+}
+
+func writeTempFile(t *testing.T, contents []byte) string {
+  name := filepath.Join(t.TempDir(), "file")
+  if err := os.WriteFile(name, contents, 0644); err != nil {
+    t.Fatalf("Got error %v", err)
+  }
+  return name
+}
+
+func readLines(r io.Reader) Stream {
+  return ReadLines(r)
+}
@@ -1,5 +1,11 @@
 package functional
 
+import (
+  "context"
+  "errors"
+  "sync"
+)
+
 // A Consumer of T consumes the T values from a Stream of T.
 type Consumer interface {
 
@@ -7,6 +13,32 @@ type Consumer interface {
   Consume(s Stream)
 }
 
+// An ErrConsumer of T consumes the T values from a Stream of T and reports
+// whether it succeeded.
+type ErrConsumer interface {
+
+  // Consume consumes values from Stream s. Consume should stop when ctx
+  // is done and return ctx.Err().
+  Consume(ctx context.Context, s Stream) error
+}
+
+// ConsumerResult is what MultiConsumeContext reports for each ErrConsumer.
+type ConsumerResult struct {
+  // Consumed is the number of values the ErrConsumer read.
+  Consumed int
+  // Stopped is true if the ErrConsumer returned without reading to the end
+  // of its Stream or if its Stream was ended early because of cancellation.
+  Stopped bool
+  // Err is the error the ErrConsumer returned.
+  Err error
+}
+
+// ToErrConsumer converts a Consumer of T to an ErrConsumer of T. The
+// returned ErrConsumer returns ctx.Err() after c returns.
+func ToErrConsumer(c Consumer) ErrConsumer {
+  return errConsumerAdapter{c}
+}
+
 // ModifyConsumerStream returns a new Consumer that applies f to its Stream
 // and then gives the result to c. If c is a Consumer of T and f takes a
 // Stream of U and returns a Stream of T, then ModifyConsumerStream returns a
@@ -26,15 +58,79 @@ func MultiConsume(s Stream, ptr interface{}, copier Copier, consumers ...Consume
     copier = assignCopier
   }
   streams := make([]*splitStream, len(consumers))
-  stillConsuming := false
   for i := range streams {
-    streams[i] = &splitStream{ptrCh: make(chan interface{}), nextReturnCh: make(chan bool)}
+    streams[i] = newSplitStream()
     go consumerWrapper(streams[i], consumers[i])
+  }
+  splitValues(context.Background(), s, ptr, copier, streams)
+}
+
+// MultiConsumeContext works like MultiConsume except that it works with
+// ErrConsumers and reports how each one fared. If an ErrConsumer returns an
+// error, MultiConsumeContext cancels the context passed to the remaining
+// ErrConsumers and ends their Streams early. MultiConsumeContext also stops
+// reading s when ctx is done. MultiConsumeContext returns a ConsumerResult
+// for each ErrConsumer in consumers along with the errors that the
+// ErrConsumers returned joined together. A context error that an
+// ErrConsumer returns after its context was already cancelled is left out
+// as it comes from the cancellation itself. If no ErrConsumer failed,
+// MultiConsumeContext returns ctx.Err().
+func MultiConsumeContext(
+    ctx context.Context,
+    s Stream,
+    ptr interface{},
+    copier Copier,
+    consumers ...ErrConsumer) ([]ConsumerResult, error) {
+  if copier == nil {
+    copier = assignCopier
+  }
+  consumerCtx, cancel := context.WithCancel(ctx)
+  defer cancel()
+  results := make([]ConsumerResult, len(consumers))
+  // cancelled[i] is true if consumerCtx was done before consumers[i]
+  // returned.
+  cancelled := make([]bool, len(consumers))
+  streams := make([]*splitStream, len(consumers))
+  for i := range streams {
+    streams[i] = newSplitStream()
+    go errConsumerWrapper(
+        consumerCtx, cancel, streams[i], consumers[i], &results[i],
+        &cancelled[i])
+  }
+  splitValues(consumerCtx, s, ptr, copier, streams)
+  var errs []error
+  for i, result := range results {
+    if result.Err != nil && !(cancelled[i] && isContextError(result.Err)) {
+      errs = append(errs, result.Err)
+    }
+  }
+  if len(errs) == 0 {
+    return results, ctx.Err()
+  }
+  return results, errors.Join(errs...)
+}
+
+// splitValues sends each value of s to streams until s is exhausted, ctx
+// is done, or no stream is accepting values. splitValues returns once each
+// consumer reading from streams has returned.
+func splitValues(
+    ctx context.Context,
+    s Stream,
+    ptr interface{},
+    copier Copier,
+    streams []*splitStream) {
+  stillConsuming := false
+  for i := range streams {
     if streams[i].cleanupIfDone() {
       stillConsuming = true
     }
   }
-  for stillConsuming && s.Next(ptr) {
+  exhausted := false
+  for stillConsuming && ctx.Err() == nil {
+    if !s.Next(ptr) {
+      exhausted = true
+      break
+    }
     stillConsuming = false
     for i := range streams {
       p := streams[i].currentPtr()
@@ -49,13 +145,78 @@ func MultiConsume(s Stream, ptr interface{}, copier Copier, consumers ...Consume
   for stillConsuming {
     stillConsuming = false
     for i := range streams {
-      if streams[i].nextReturn(false) {
+      if streams[i].endEarly(!exhausted) {
         stillConsuming = true
       }
     }
   }
 }
 
+// MultiConsumeBuffered works like MultiConsume except that each Consumer
+// in consumers runs on its own goroutine at its own pace. Rather than
+// handing each value to every Consumer in turn, MultiConsumeBuffered reads
+// values from s, a Stream of T, in batches of batchSize and gives each
+// Consumer a buffer that holds up to bufferSize batches. MultiConsumeBuffered
+// blocks reading s only when the buffer of some Consumer still accepting
+// values is full. c is a Creater of T used to create the values in the
+// batches up front. copier is a Copier of T used to copy values into the
+// batches and out of them to each Consumer; passing nil for copier means
+// use simple assignment. Because values are read a batch at a time,
+// MultiConsumeBuffered may read up to batchSize values from s that no
+// Consumer sees when all Consumers stop early. If batchSize or bufferSize
+// is less than 1, 1 is used.
+func MultiConsumeBuffered(
+    s Stream,
+    c Creater,
+    copier Copier,
+    batchSize int,
+    bufferSize int,
+    consumers ...Consumer) {
+  if copier == nil {
+    copier = assignCopier
+  }
+  if batchSize < 1 {
+    batchSize = 1
+  }
+  if bufferSize < 1 {
+    bufferSize = 1
+  }
+  var wg sync.WaitGroup
+  streams := make([]*batchStream, len(consumers))
+  for i := range streams {
+    streams[i] = newBatchStream(c, copier, batchSize, bufferSize)
+    wg.Add(1)
+    go func(s *batchStream, c Consumer) {
+      defer wg.Done()
+      s.consume(c)
+    }(streams[i], consumers[i])
+  }
+  batch := newBatch(c, batchSize)
+  for len(streams) > 0 {
+    n := 0
+    for n < batchSize && s.Next(batch[n]) {
+      n++
+    }
+    if n == 0 {
+      break
+    }
+    active := streams[:0]
+    for _, stream := range streams {
+      if stream.send(batch[:n]) {
+        active = append(active, stream)
+      }
+    }
+    streams = active
+    if n < batchSize {
+      break
+    }
+  }
+  for _, stream := range streams {
+    close(stream.filled)
+  }
+  wg.Wait()
+}
+
 type modifiedConsumerStream struct {
   c Consumer
   f func(s Stream) Stream
@@ -70,10 +231,63 @@ func consumerWrapper(s *splitStream, c Consumer) {
   s.ptrCh <- nil
 }
 
+func errConsumerWrapper(
+    ctx context.Context,
+    cancel context.CancelFunc,
+    s *splitStream,
+    c ErrConsumer,
+    result *ConsumerResult,
+    cancelled *bool) {
+  counter := &countingStream{Stream: s}
+  result.Err = c.Consume(ctx, counter)
+  *cancelled = ctx.Err() != nil
+  result.Consumed = counter.count
+  result.Stopped = !counter.exhausted || s.cutShort
+  if result.Err != nil {
+    cancel()
+  }
+  s.ptrCh <- nil
+}
+
+func isContextError(err error) bool {
+  return errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded)
+}
+
+type errConsumerAdapter struct {
+  c Consumer
+}
+
+func (a errConsumerAdapter) Consume(ctx context.Context, s Stream) error {
+  a.c.Consume(s)
+  return ctx.Err()
+}
+
+// countingStream counts the values read from a Stream.
+type countingStream struct {
+  Stream
+  count int
+  exhausted bool
+}
+
+func (s *countingStream) Next(ptr interface{}) bool {
+  if s.Stream.Next(ptr) {
+    s.count++
+    return true
+  }
+  s.exhausted = true
+  return false
+}
+
 type splitStream struct {
   ptrCh chan interface{}
   nextReturnCh chan bool
   ptr interface{}
+  // cutShort is true if the stream was ended before the values ran out.
+  cutShort bool
+}
+
+func newSplitStream() *splitStream {
+  return &splitStream{ptrCh: make(chan interface{}), nextReturnCh: make(chan bool)}
 }
 
 func (s *splitStream) Next(ptr interface{}) bool {
@@ -93,6 +307,16 @@ func (s *splitStream) nextReturn(returnValue bool) bool {
   return s.cleanupIfDone()
 }
 
+// endEarly works like nextReturn(false) and records whether the stream was
+// cut short.
+func (s *splitStream) endEarly(cutShort bool) bool {
+  if s.nextReturnCh == nil {
+    return false
+  }
+  s.cutShort = cutShort
+  return s.nextReturn(false)
+}
+
 func (s *splitStream) cleanupIfDone() bool {
   s.ptr = <-s.ptrCh
   if s.ptr == nil {
@@ -105,3 +329,79 @@ func (s *splitStream) cleanupIfDone() bool {
   return true
 }
 
+
+// batchStream is the Stream that MultiConsumeBuffered gives to a Consumer.
+// Batches go back and forth between the producer and the Consumer on the
+// free and filled channels.
+type batchStream struct {
+  copier Copier
+  free chan []interface{}
+  filled chan []interface{}
+  // quit is closed when the Consumer returns.
+  quit chan struct{}
+  current []interface{}
+  index int
+  exhausted bool
+}
+
+func newBatchStream(c Creater, copier Copier, batchSize, bufferSize int) *batchStream {
+  result := &batchStream{
+      copier: copier,
+      free: make(chan []interface{}, bufferSize),
+      filled: make(chan []interface{}, bufferSize),
+      quit: make(chan struct{})}
+  for i := 0; i < bufferSize; i++ {
+    result.free <- newBatch(c, batchSize)
+  }
+  return result
+}
+
+func (s *batchStream) Next(ptr interface{}) bool {
+  for s.index == len(s.current) {
+    if s.exhausted {
+      return false
+    }
+    if s.current != nil {
+      s.free <- s.current[:cap(s.current)]
+    }
+    var ok bool
+    if s.current, ok = <-s.filled; !ok {
+      s.exhausted = true
+      s.current = nil
+      return false
+    }
+    s.index = 0
+  }
+  s.copier(s.current[s.index], ptr)
+  s.index++
+  return true
+}
+
+func (s *batchStream) consume(c Consumer) {
+  defer close(s.quit)
+  c.Consume(s)
+}
+
+// send copies values into a free batch and queues it for the Consumer.
+// send returns false if the Consumer has returned.
+func (s *batchStream) send(values []interface{}) bool {
+  var batch []interface{}
+  select {
+  case <-s.quit:
+    return false
+  case batch = <-s.free:
+  }
+  for i := range values {
+    s.copier(values[i], batch[i])
+  }
+  s.filled <- batch[:len(values)]
+  return true
+}
+
+func newBatch(c Creater, batchSize int) []interface{} {
+  result := make([]interface{}, batchSize)
+  for i := range result {
+    result[i] = c()
+  }
+  return result
+}
@@ -1,6 +1,8 @@
 package functional
 
 import (
+    "context"
+    "errors"
     "fmt"
     "testing"
 )
@@ -84,6 +86,184 @@ func TestReadPastEndConsumer(t *testing.T) {
   }
 }
 
+func TestMultiConsumeBuffered(t *testing.T) {
+  s := Slice(Count(), 0, 25)
+  ec := newEvenNumberConsumer()
+  oc := newOddNumberConsumer()
+  MultiConsumeBuffered(s, func() interface{} { return new(int) }, nil, 4, 2, ec, oc)
+  if output := fmt.Sprintf("%v", ec.results); output != "[0 2 4 6 8 10 12 14 16 18 20 22 24]" {
+    t.Errorf("Expected [0 2 4 6 8 10 12 14 16 18 20 22 24] got %v", output)
+  }
+  if output := fmt.Sprintf("%v", oc.results); output != "[1 3 5 7 9 11 13 15 17 19 21 23]" {
+    t.Errorf("Expected [1 3 5 7 9 11 13 15 17 19 21 23] got %v", output)
+  }
+}
+
+func TestMultiConsumeBufferedConsumersEndEarly(t *testing.T) {
+  first5 := func(s Stream) Stream {
+    return Slice(s, 0, 5)
+  }
+  ec := newEvenNumberConsumer()
+  oc := newOddNumberConsumer()
+  nc := &noNextConsumer{}
+  rc := &readPastEndConsumer{}
+  MultiConsumeBuffered(
+      Count(),
+      func() interface{} { return new(int) },
+      nil,
+      3,
+      2,
+      nc,
+      ModifyConsumerStream(ec, first5),
+      ModifyConsumerStream(oc, first5),
+      ModifyConsumerStream(rc, first5))
+  if output := fmt.Sprintf("%v", ec.results); output != "[0 2 4]" {
+    t.Errorf("Expected [0 2 4] got %v", output)
+  }
+  if output := fmt.Sprintf("%v", oc.results); output != "[1 3]" {
+    t.Errorf("Expected [1 3] got %v", output)
+  }
+  if !nc.completed || !rc.completed {
+    t.Error("MultiConsumeBuffered returned before child consumers completed.")
+  }
+}
+
+func TestMultiConsumeBufferedCopier(t *testing.T) {
+  s := NewStreamFromValues([][]int{{1}, {2, 3}, {4}})
+  creater := func() interface{} { return new([]int) }
+  copier := func(src, dest interface{}) {
+    *dest.(*[]int) = append((*dest.(*[]int))[:0], *src.(*[]int)...)
+  }
+  var c1, c2 sliceConsumer
+  MultiConsumeBuffered(s, creater, copier, 2, 1, &c1, &c2)
+  if output := fmt.Sprintf("%v %v", c1.results, c2.results); output != "[[1] [2 3] [4]] [[1] [2 3] [4]]" {
+    t.Errorf("Expected [[1] [2 3] [4]] twice got %v", output)
+  }
+}
+
+func TestMultiConsumeBufferedNoConsumers(t *testing.T) {
+  s := CountFrom(7, 1)
+  MultiConsumeBuffered(s, func() interface{} { return new(int) }, nil, 10, 10)
+  var result int
+  if !s.Next(&result) || result != 7 {
+    t.Errorf("Expected 7 got %v", result)
+  }
+}
+
+func TestMultiConsumeContext(t *testing.T) {
+  ec := newEvenNumberConsumer()
+  first3 := func(s Stream) Stream {
+    return Slice(s, 0, 3)
+  }
+  results, err := MultiConsumeContext(
+      context.Background(),
+      Slice(Count(), 0, 6),
+      new(int),
+      nil,
+      ToErrConsumer(ec),
+      ToErrConsumer(ModifyConsumerStream(&sumConsumer{}, first3)))
+  if err != nil {
+    t.Errorf("Expected no error got %v", err)
+  }
+  if output := fmt.Sprintf("%v", ec.results); output != "[0 2 4]" {
+    t.Errorf("Expected [0 2 4] got %v", output)
+  }
+  if output := fmt.Sprintf("%v", results); output != "[{6 false <nil>} {3 true <nil>}]" {
+    t.Errorf("Expected [{6 false <nil>} {3 true <nil>}] got %v", output)
+  }
+}
+
+func TestMultiConsumeContextFailure(t *testing.T) {
+  ec := newEvenNumberConsumer()
+  results, err := MultiConsumeContext(
+      context.Background(),
+      Count(),
+      new(int),
+      nil,
+      ToErrConsumer(ec),
+      &failingConsumer{failAt: 4},
+      &ctxSumConsumer{})
+  if err == nil || err.Error() != "Failed at 4" {
+    t.Errorf("Expected 'Failed at 4' got %v", err)
+  }
+  if output := fmt.Sprintf("%v", ec.results); output != "[0 2 4]" {
+    t.Errorf("Expected [0 2 4] got %v", output)
+  }
+  if results[1].Consumed != 5 || !results[1].Stopped || results[1].Err == nil {
+    t.Errorf("Expected failing consumer to read 5 values got %v", results[1])
+  }
+  if !errors.Is(results[2].Err, context.Canceled) {
+    t.Errorf("Expected cancellation got %v", results[2].Err)
+  }
+  if results[2].Consumed != 5 || !results[2].Stopped {
+    t.Errorf("Expected 5 values and stopped got %v", results[2])
+  }
+}
+
+func TestMultiConsumeContextOwnContextError(t *testing.T) {
+  results, err := MultiConsumeContext(
+      context.Background(),
+      Count(),
+      new(int),
+      nil,
+      &timingOutConsumer{failAt: 3},
+      &ctxSumConsumer{})
+  if !errors.Is(err, context.DeadlineExceeded) {
+    t.Errorf("Expected context.DeadlineExceeded got %v", err)
+  }
+  if !errors.Is(results[0].Err, context.DeadlineExceeded) {
+    t.Errorf("Expected context.DeadlineExceeded got %v", results[0].Err)
+  }
+  if !errors.Is(results[1].Err, context.Canceled) || !results[1].Stopped {
+    t.Errorf("Expected cancelled and stopped got %v", results[1])
+  }
+}
+
+func TestMultiConsumeContextMultipleFailures(t *testing.T) {
+  _, err := MultiConsumeContext(
+      context.Background(),
+      Count(),
+      new(int),
+      nil,
+      &failingConsumer{failAt: 0},
+      &failingConsumer{failAt: 0})
+  if output := fmt.Sprintf("%q", err.Error()); output != `"Failed at 0\nFailed at 0"` {
+    t.Errorf("Expected both errors got %v", output)
+  }
+}
+
+func TestMultiConsumeContextCancelled(t *testing.T) {
+  ctx, cancel := context.WithCancel(context.Background())
+  cancel()
+  s := CountFrom(7, 1)
+  results, err := MultiConsumeContext(ctx, s, new(int), nil, &ctxSumConsumer{})
+  if err != context.Canceled {
+    t.Errorf("Expected context.Canceled got %v", err)
+  }
+  if results[0].Consumed != 0 {
+    t.Errorf("Expected no values consumed got %v", results[0].Consumed)
+  }
+  var result int
+  if !s.Next(&result) || result != 7 {
+    t.Errorf("Expected 7 got %v", result)
+  }
+}
+
+func BenchmarkMultiConsume(b *testing.B) {
+  consumers := []Consumer{&sumConsumer{}, &sumConsumer{}, &sumConsumer{}}
+  for i := 0; i < b.N; i++ {
+    MultiConsume(Slice(Count(), 0, 1000), new(int), nil, consumers...)
+  }
+}
+
+func BenchmarkMultiConsumeBuffered(b *testing.B) {
+  consumers := []Consumer{&sumConsumer{}, &sumConsumer{}, &sumConsumer{}}
+  creater := func() interface{} { return new(int) }
+  for i := 0; i < b.N; i++ {
+    MultiConsumeBuffered(Slice(Count(), 0, 1000), creater, nil, 100, 4, consumers...)
+  }
+}
+
 type filterConsumer struct {
   f Filterer
   results []int
@@ -128,3 +308,65 @@ func newOddNumberConsumer() *filterConsumer {
     return *p % 2 == 1
   })}
 }
+
+type sliceConsumer struct {
+  results [][]int
+}
+
+func (c *sliceConsumer) Consume(s Stream) {
+  var x []int
+  for s.Next(&x) {
+    c.results = append(c.results, append([]int(nil), x...))
+  }
+}
+
+type sumConsumer struct {
+  sum int
+}
+
+func (c *sumConsumer) Consume(s Stream) {
+  var x int
+  for s.Next(&x) {
+    c.sum += x
+  }
+}
+
+// failingConsumer fails upon reading failAt.
+type failingConsumer struct {
+  failAt int
+}
+
+func (c *failingConsumer) Consume(ctx context.Context, s Stream) error {
+  var x int
+  for s.Next(&x) {
+    if x == c.failAt {
+      return fmt.Errorf("Failed at %d", x)
+    }
+  }
+  return ctx.Err()
+}
+
+// timingOutConsumer fails with its own deadline error as a consumer whose
+// database call timed out would.
+type timingOutConsumer struct {
+  failAt int
+}
+
+func (c *timingOutConsumer) Consume(ctx context.Context, s Stream) error {
+  var x int
+  for s.Next(&x) {
+    if x == c.failAt {
+      return fmt.Errorf("Query failed: %w", context.DeadlineExceeded)
+    }
+  }
+  return ctx.Err()
+}
+
+type ctxSumConsumer struct {
+  sumConsumer
+}
+
+func (c *ctxSumConsumer) Consume(ctx context.Context, s Stream) error {
+  c.sumConsumer.Consume(s)
+  return ctx.Err()
+}
@@ -0,0 +1,177 @@
+package functional
+
+import (
+  "database/sql"
+  "errors"
+  "reflect"
+  "strings"
+)
+
+const (
+  kDefaultBatchSize = 100
+)
+
+// DBWriter is a Consumer of T that writes the T values it consumes to a
+// database table using multi-row INSERT statements. *T must implement
+// Tuple. DBWriter groups consumed values into batches of BatchSize rows,
+// writes each batch with a single statement, and commits after every
+// BatchesPerTx batches. If a statement fails, DBWriter rolls back the
+// current transaction and stops consuming. Once Consume returns,
+// RowsWritten and Err report the results.
+type DBWriter struct {
+  // DB is the database to write to.
+  DB *sql.DB
+  // Creater is a Creater of T. It creates the values that hold each batch.
+  Creater Creater
+  // Table is the name of the table.
+  Table string
+  // Columns are the names of the columns to write.
+  Columns []string
+  // Fields maps each column to an index in the slice that Ptrs returns.
+  // If nil, the first len(Columns) values Ptrs returns are used in order.
+  Fields []int
+  // Verb is the statement verb e.g "INSERT OR REPLACE". If empty,
+  // "INSERT" is used.
+  Verb string
+  // Suffix is appended to each statement. Use it for UPSERT clauses such as
+  // "ON CONFLICT (id) DO UPDATE SET name = excluded.name".
+  Suffix string
+  // Placeholder returns the placeholder for the nth argument of a
+  // statement. n starts at 1. If nil, "?" is used for every argument.
+  Placeholder func(n int) string
+  // BatchSize is the number of rows written with each statement. If
+  // BatchSize <= 0, 100 is used.
+  BatchSize int
+  // BatchesPerTx is the number of batches written in each transaction. If
+  // BatchesPerTx <= 0, 1 is used.
+  BatchesPerTx int
+  // RowsWritten is the number of rows committed to the database.
+  RowsWritten int64
+  // Err is the first error encountered or nil if there was no error.
+  Err error
+}
+
+// Consume writes the values in s, a Stream of T, to the database.
+func (w *DBWriter) Consume(s Stream) {
+  if w.Creater == nil {
+    w.Err = errors.New("DBWriter: Creater required.")
+    return
+  }
+  batchSize := w.BatchSize
+  if batchSize <= 0 {
+    batchSize = kDefaultBatchSize
+  }
+  batchesPerTx := w.BatchesPerTx
+  if batchesPerTx <= 0 {
+    batchesPerTx = 1
+  }
+  batchPtr := w.newBatch(batchSize)
+  batches := PartitionPtrs(s)
+  fullStatement := w.statement(batchSize)
+  var tx *sql.Tx
+  var numBatches int
+  var rowsInTx int64
+  for batches.Next(batchPtr.Interface()) {
+    if tx == nil {
+      var err error
+      if tx, err = w.DB.Begin(); err != nil {
+        w.Err = err
+        return
+      }
+    }
+    batch := batchPtr.Elem()
+    statement := fullStatement
+    if batch.Len() < batchSize {
+      statement = w.statement(batch.Len())
+    }
+    if _, err := tx.Exec(statement, w.args(batch)...); err != nil {
+      tx.Rollback()
+      w.Err = err
+      return
+    }
+    numBatches++
+    rowsInTx += int64(batch.Len())
+    if numBatches % batchesPerTx == 0 {
+      if !w.commit(tx, rowsInTx) {
+        return
+      }
+      tx = nil
+      rowsInTx = 0
+    }
+  }
+  if tx != nil {
+    w.commit(tx, rowsInTx)
+  }
+}
+
+func (w *DBWriter) commit(tx *sql.Tx, rows int64) bool {
+  if err := tx.Commit(); err != nil {
+    w.Err = err
+    return false
+  }
+  w.RowsWritten += rows
+  return true
+}
+
+// newBatch returns a *[]*T pointing to a slice of length size.
+func (w *DBWriter) newBatch(size int) reflect.Value {
+  sliceType := reflect.SliceOf(reflect.TypeOf(w.Creater()))
+  result := reflect.New(sliceType)
+  result.Elem().Set(reflect.MakeSlice(sliceType, size, size))
+  InitPtrs(result.Elem().Interface(), w.Creater)
+  return result
+}
+
+func (w *DBWriter) args(batch reflect.Value) []interface{} {
+  length := batch.Len()
+  result := make([]interface{}, 0, length * len(w.Columns))
+  for i := 0; i < length; i++ {
+    ptrs := batch.Index(i).Interface().(Tuple).Ptrs()
+    for j := range w.Columns {
+      if w.Fields == nil {
+        result = append(result, ptrs[j])
+      } else {
+        result = append(result, ptrs[w.Fields[j]])
+      }
+    }
+  }
+  return result
+}
+
+func (w *DBWriter) statement(rows int) string {
+  verb := w.Verb
+  if verb == "" {
+    verb = "INSERT"
+  }
+  placeholder := w.Placeholder
+  if placeholder == nil {
+    placeholder = func(n int) string { return "?" }
+  }
+  var b strings.Builder
+  b.WriteString(verb)
+  b.WriteString(" INTO ")
+  b.WriteString(w.Table)
+  b.WriteString(" (")
+  b.WriteString(strings.Join(w.Columns, ", "))
+  b.WriteString(") VALUES ")
+  n := 1
+  for i := 0; i < rows; i++ {
+    if i > 0 {
+      b.WriteString(", ")
+    }
+    b.WriteString("(")
+    for j := range w.Columns {
+      if j > 0 {
+        b.WriteString(", ")
+      }
+      b.WriteString(placeholder(n))
+      n++
+    }
+    b.WriteString(")")
+  }
+  if w.Suffix != "" {
+    b.WriteString(" ")
+    b.WriteString(w.Suffix)
+  }
+  return b.String()
+}
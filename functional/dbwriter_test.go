@@ -0,0 +1,244 @@
+package functional
+
+import (
+    "context"
+    "database/sql"
+    "database/sql/driver"
+    "errors"
+    "fmt"
+    "strconv"
+    "sync"
+    "testing"
+)
+
+var (
+  execError = errors.New("error executing.")
+)
+
+func TestDBWriter(t *testing.T) {
+  fdb := &fakeDB{}
+  w := &DBWriter{
+      DB: sql.OpenDB(fdb),
+      Creater: func() interface{} { return new(intAndString) },
+      Table: "people",
+      Columns: []string{"id", "name"},
+      BatchSize: 2,
+      BatchesPerTx: 2}
+  w.Consume(NewStreamFromValues(people(5)))
+  if w.Err != nil {
+    t.Errorf("Expected no error got %v", w.Err)
+  }
+  if w.RowsWritten != 5 {
+    t.Errorf("Expected 5 rows written got %v", w.RowsWritten)
+  }
+  expected := []string{
+      "INSERT INTO people (id, name) VALUES (?, ?), (?, ?) [0 n0 1 n1]",
+      "INSERT INTO people (id, name) VALUES (?, ?), (?, ?) [2 n2 3 n3]",
+      "INSERT INTO people (id, name) VALUES (?, ?) [4 n4]"}
+  if output := fmt.Sprintf("%v", fdb.committed); output != fmt.Sprintf("%v", expected) {
+    t.Errorf("Expected %v got %v", expected, output)
+  }
+  if fdb.commits != 2 {
+    t.Errorf("Expected 2 commits got %v", fdb.commits)
+  }
+}
+
+func TestDBWriterUpsert(t *testing.T) {
+  fdb := &fakeDB{}
+  w := &DBWriter{
+      DB: sql.OpenDB(fdb),
+      Creater: func() interface{} { return new(intAndString) },
+      Table: "people",
+      Columns: []string{"name", "id"},
+      Fields: []int{1, 0},
+      Suffix: "ON CONFLICT (id) DO UPDATE SET name = excluded.name",
+      Placeholder: func(n int) string { return "$" + strconv.Itoa(n) },
+      BatchSize: 3}
+  w.Consume(NewStreamFromValues(people(2)))
+  expected := []string{
+      "INSERT INTO people (name, id) VALUES ($1, $2), ($3, $4) ON CONFLICT (id) DO UPDATE SET name = excluded.name [n0 0 n1 1]"}
+  if output := fmt.Sprintf("%v", fdb.committed); output != fmt.Sprintf("%v", expected) {
+    t.Errorf("Expected %v got %v", expected, output)
+  }
+  if w.RowsWritten != 2 {
+    t.Errorf("Expected 2 rows written got %v", w.RowsWritten)
+  }
+}
+
+func TestDBWriterEmpty(t *testing.T) {
+  fdb := &fakeDB{}
+  w := &DBWriter{
+      DB: sql.OpenDB(fdb),
+      Creater: func() interface{} { return new(intAndString) },
+      Table: "people",
+      Columns: []string{"id", "name"}}
+  w.Consume(NilStream())
+  if w.Err != nil || w.RowsWritten != 0 || fdb.commits != 0 {
+    t.Error("Expected nothing written.")
+  }
+}
+
+func TestDBWriterError(t *testing.T) {
+  fdb := &fakeDB{failOnExec: 3}
+  w := &DBWriter{
+      DB: sql.OpenDB(fdb),
+      Creater: func() interface{} { return new(intAndString) },
+      Table: "people",
+      Columns: []string{"id", "name"},
+      BatchSize: 1,
+      BatchesPerTx: 2}
+  s := NewStreamFromValues(people(5))
+  w.Consume(s)
+  if w.Err != execError {
+    t.Errorf("Expected execError got %v", w.Err)
+  }
+  if w.RowsWritten != 2 {
+    t.Errorf("Expected 2 rows written got %v", w.RowsWritten)
+  }
+  if fdb.rollbacks != 1 {
+    t.Errorf("Expected 1 rollback got %v", fdb.rollbacks)
+  }
+  var p intAndString
+  if !s.Next(&p) || p.id != 3 {
+    t.Error("DBWriter should stop consuming after an error.")
+  }
+}
+
+func TestDBWriterMultiConsume(t *testing.T) {
+  fdb := &fakeDB{}
+  w := &DBWriter{
+      DB: sql.OpenDB(fdb),
+      Creater: func() interface{} { return new(intAndString) },
+      Table: "people",
+      Columns: []string{"id"},
+      BatchSize: 10}
+  var ids []int
+  MultiConsume(
+      NewStreamFromValues(people(3)),
+      new(intAndString),
+      nil,
+      w,
+      ModifyConsumerStream(
+          idConsumer{&ids},
+          func(s Stream) Stream { return s }))
+  if w.RowsWritten != 3 {
+    t.Errorf("Expected 3 rows written got %v", w.RowsWritten)
+  }
+  if output := fmt.Sprintf("%v", ids); output != "[0 1 2]" {
+    t.Errorf("Expected [0 1 2] got %v", output)
+  }
+}
+
+type idConsumer struct {
+  ids *[]int
+}
+
+func (c idConsumer) Consume(s Stream) {
+  var p intAndString
+  for s.Next(&p) {
+    *c.ids = append(*c.ids, p.id)
+  }
+}
+
+func people(n int) []intAndString {
+  result := make([]intAndString, n)
+  for i := range result {
+    result[i] = intAndString{i, "n" + strconv.Itoa(i)}
+  }
+  return result
+}
+
+// fakeDB is an in-process database driver that records the statements
+// executed in committed transactions.
+type fakeDB struct {
+  mu sync.Mutex
+  committed []string
+  commits int
+  rollbacks int
+  execs int
+  // failOnExec, if non-zero, makes the nth call to Exec fail.
+  failOnExec int
+}
+
+func (d *fakeDB) Connect(ctx context.Context) (driver.Conn, error) {
+  return &fakeConn{db: d}, nil
+}
+
+func (d *fakeDB) Driver() driver.Driver {
+  return fakeDriver{d}
+}
+
+type fakeDriver struct {
+  db *fakeDB
+}
+
+func (d fakeDriver) Open(name string) (driver.Conn, error) {
+  return &fakeConn{db: d.db}, nil
+}
+
+type fakeConn struct {
+  db *fakeDB
+  pending []string
+}
+
+func (c *fakeConn) Prepare(query string) (driver.Stmt, error) {
+  return &fakeStmt{c, query}, nil
+}
+
+func (c *fakeConn) Close() error {
+  return nil
+}
+
+func (c *fakeConn) Begin() (driver.Tx, error) {
+  c.pending = nil
+  return fakeTx{c}, nil
+}
+
+type fakeTx struct {
+  c *fakeConn
+}
+
+func (t fakeTx) Commit() error {
+  t.c.db.mu.Lock()
+  defer t.c.db.mu.Unlock()
+  t.c.db.committed = append(t.c.db.committed, t.c.pending...)
+  t.c.db.commits++
+  t.c.pending = nil
+  return nil
+}
+
+func (t fakeTx) Rollback() error {
+  t.c.db.mu.Lock()
+  defer t.c.db.mu.Unlock()
+  t.c.db.rollbacks++
+  t.c.pending = nil
+  return nil
+}
+
+type fakeStmt struct {
+  c *fakeConn
+  query string
+}
+
+func (s *fakeStmt) Close() error {
+  return nil
+}
+
+func (s *fakeStmt) NumInput() int {
+  return -1
+}
+
+func (s *fakeStmt) Exec(args []driver.Value) (driver.Result, error) {
+  s.c.db.mu.Lock()
+  defer s.c.db.mu.Unlock()
+  s.c.db.execs++
+  if s.c.db.execs == s.c.db.failOnExec {
+    return nil, execError
+  }
+  s.c.pending = append(s.c.pending, fmt.Sprintf("%s %v", s.query, args))
+  return driver.RowsAffected(len(args)), nil
+}
+
+func (s *fakeStmt) Query(args []driver.Value) (driver.Rows, error) {
+  return nil, errors.New("Query not supported.")
+}
@@ -0,0 +1,212 @@
+package functional
+
+import (
+  "hash/maphash"
+)
+
+// DistributePolicy decides which worker gets each value in Distribute.
+type DistributePolicy interface {
+  // chooser returns a function that returns the index of the worker to get
+  // the value at ptr or -1 if any idle worker may have it. alive reports
+  // which workers are still accepting values.
+  chooser() func(ptr interface{}, alive []bool) int
+}
+
+// RoundRobin returns a DistributePolicy that gives values to each worker
+// in turn.
+func RoundRobin() DistributePolicy {
+  return roundRobinPolicy{}
+}
+
+// LeastBusy returns a DistributePolicy that gives each value to whichever
+// worker asks for it first.
+func LeastBusy() DistributePolicy {
+  return leastBusyPolicy{}
+}
+
+// KeyAffinity returns a DistributePolicy that gives all values with the
+// same key to the same worker, so that each worker sees the values for
+// each of its keys in order. k returns the key of each value; keys must be
+// comparable. If a worker stops accepting values, its keys move to other
+// workers.
+func KeyAffinity(k KeyFunc) DistributePolicy {
+  return keyAffinityPolicy{k}
+}
+
+// Distribute consumes the values of s, a Stream of T, giving each value to
+// exactly one Consumer in workers according to policy. Each Consumer runs
+// on its own goroutine so that workers process values at the same time. ptr
+// is a *T that receives the values from s. copier is a Copier of T used to
+// copy each value to the Stream of the chosen worker. Passing nil for
+// copier means use simple assignment. Distribute consumes values from s
+// until either s is exhausted or until no worker is accepting values, and
+// returns once every worker has returned.
+func Distribute(
+    s Stream,
+    ptr interface{},
+    copier Copier,
+    policy DistributePolicy,
+    workers ...Consumer) {
+  if copier == nil {
+    copier = assignCopier
+  }
+  d := &distributor{
+      requests: make(chan workerRequest),
+      idle: make([]interface{}, len(workers)),
+      alive: make([]bool, len(workers)),
+      replies: make([]chan bool, len(workers)),
+      aliveCount: len(workers)}
+  for i := range workers {
+    d.alive[i] = true
+    d.replies[i] = make(chan bool)
+    go d.runWorker(i, workers[i])
+  }
+  choose := policy.chooser()
+  for d.aliveCount > 0 && s.Next(ptr) {
+    i := -1
+    for d.aliveCount > 0 && i < 0 {
+      i = d.waitForWorker(choose(ptr, d.alive))
+    }
+    if i < 0 {
+      break
+    }
+    copier(ptr, d.idle[i])
+    d.idle[i] = nil
+    d.replies[i] <- true
+  }
+  for i := range d.idle {
+    if d.idle[i] != nil {
+      d.idle[i] = nil
+      d.replies[i] <- false
+    }
+  }
+  for d.aliveCount > 0 {
+    r := <-d.requests
+    if r.ptr != nil {
+      d.replies[r.worker] <- false
+    } else {
+      d.markDone(r.worker)
+    }
+  }
+}
+
+// workerRequest is how a worker asks for its next value. A nil ptr means
+// the worker returned.
+type workerRequest struct {
+  worker int
+  ptr interface{}
+}
+
+type distributor struct {
+  requests chan workerRequest
+  // idle holds the ptr of each worker waiting for a value.
+  idle []interface{}
+  alive []bool
+  replies []chan bool
+  aliveCount int
+}
+
+func (d *distributor) runWorker(i int, c Consumer) {
+  c.Consume(&workerStream{d: d, worker: i})
+  d.requests <- workerRequest{worker: i}
+}
+
+// waitForWorker waits until worker i is idle and returns i. If i is -1,
+// waitForWorker waits for any worker to be idle and returns it.
+// waitForWorker returns -1 if the worker it waits for stops accepting
+// values.
+func (d *distributor) waitForWorker(i int) int {
+  for {
+    if i >= 0 {
+      if d.idle[i] != nil {
+        return i
+      }
+      if !d.alive[i] {
+        return -1
+      }
+    } else {
+      for j := range d.idle {
+        if d.idle[j] != nil {
+          return j
+        }
+      }
+      if d.aliveCount == 0 {
+        return -1
+      }
+    }
+    r := <-d.requests
+    if r.ptr != nil {
+      d.idle[r.worker] = r.ptr
+    } else {
+      d.markDone(r.worker)
+    }
+  }
+}
+
+func (d *distributor) markDone(i int) {
+  d.alive[i] = false
+  d.aliveCount--
+}
+
+type workerStream struct {
+  d *distributor
+  worker int
+  exhausted bool
+}
+
+func (s *workerStream) Next(ptr interface{}) bool {
+  if s.exhausted {
+    return false
+  }
+  s.d.requests <- workerRequest{worker: s.worker, ptr: ptr}
+  if !<-s.d.replies[s.worker] {
+    s.exhausted = true
+    return false
+  }
+  return true
+}
+
+type roundRobinPolicy struct {
+}
+
+func (p roundRobinPolicy) chooser() func(ptr interface{}, alive []bool) int {
+  next := 0
+  return func(ptr interface{}, alive []bool) int {
+    result := nextAlive(next, alive)
+    next = result + 1
+    return result
+  }
+}
+
+type leastBusyPolicy struct {
+}
+
+func (p leastBusyPolicy) chooser() func(ptr interface{}, alive []bool) int {
+  return func(ptr interface{}, alive []bool) int {
+    return -1
+  }
+}
+
+type keyAffinityPolicy struct {
+  k KeyFunc
+}
+
+func (p keyAffinityPolicy) chooser() func(ptr interface{}, alive []bool) int {
+  seed := maphash.MakeSeed()
+  return func(ptr interface{}, alive []bool) int {
+    h := maphash.Comparable(seed, p.k(ptr))
+    return nextAlive(int(h % uint64(len(alive))), alive)
+  }
+}
+
+// nextAlive returns the index of the first alive worker starting at start
+// and wrapping around. If no worker is alive, nextAlive returns -1.
+func nextAlive(start int, alive []bool) int {
+  for i := 0; i < len(alive); i++ {
+    index := (start + i) % len(alive)
+    if alive[index] {
+      return index
+    }
+  }
+  return -1
+}
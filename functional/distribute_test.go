@@ -0,0 +1,158 @@
+package functional
+
+import (
+    "fmt"
+    "sort"
+    "sync"
+    "testing"
+)
+
+func TestDistributeRoundRobin(t *testing.T) {
+  workers := []*collectingWorker{{}, {}, {}}
+  Distribute(Slice(Count(), 0, 8), new(int), nil, RoundRobin(), asConsumers(workers)...)
+  if output := fmt.Sprintf("%v %v %v", workers[0].values, workers[1].values, workers[2].values); output != "[0 3 6] [1 4 7] [2 5]" {
+    t.Errorf("Expected [0 3 6] [1 4 7] [2 5] got %v", output)
+  }
+}
+
+func TestDistributeLeastBusy(t *testing.T) {
+  blocked := &collectingWorker{gate: make(chan struct{})}
+  free := &collectingWorker{}
+  done := make(chan struct{})
+  go func() {
+    Distribute(Slice(Count(), 0, 10), new(int), nil, LeastBusy(), blocked, free)
+    close(done)
+  }()
+  // While blocked is stuck on its first value, free gets the rest.
+  for free.count() < 9 {
+  }
+  close(blocked.gate)
+  <-done
+  if total := blocked.count() + free.count(); total != 10 {
+    t.Errorf("Expected 10 values got %v", total)
+  }
+  if n := blocked.count(); n > 1 {
+    t.Errorf("Expected at most 1 value for blocked worker got %v", n)
+  }
+}
+
+func TestDistributeKeyAffinity(t *testing.T) {
+  var txns []txn
+  for i := 0; i < 60; i++ {
+    txns = append(txns, txn{fmt.Sprintf("a%d", i % 7), i})
+  }
+  workers := make([]*txnWorker, 4)
+  consumers := make([]Consumer, len(workers))
+  for i := range workers {
+    workers[i] = &txnWorker{}
+    consumers[i] = workers[i]
+  }
+  Distribute(
+      NewStreamFromValues(txns),
+      new(txn),
+      nil,
+      KeyAffinity(func(ptr interface{}) interface{} { return ptr.(*txn).Account }),
+      consumers...)
+  owner := make(map[string]int)
+  total := 0
+  for i, w := range workers {
+    for _, entry := range w.entries {
+      account := entry.Account
+      if prev, ok := owner[account]; ok && prev != i {
+        t.Errorf("Account %v went to workers %v and %v", account, prev, i)
+      }
+      owner[account] = i
+      total++
+    }
+    if !sort.SliceIsSorted(w.entries, func(a, b int) bool { return w.entries[a].Seq < w.entries[b].Seq }) {
+      t.Errorf("Expected worker %v to see values in order", i)
+    }
+  }
+  if total != 60 {
+    t.Errorf("Expected 60 values got %v", total)
+  }
+}
+
+func TestDistributeWorkersEndEarly(t *testing.T) {
+  first2 := func(s Stream) Stream {
+    return Slice(s, 0, 2)
+  }
+  w1 := &collectingWorker{}
+  w2 := &collectingWorker{}
+  nc := &noNextConsumer{}
+  s := Count()
+  Distribute(
+      s,
+      new(int),
+      nil,
+      RoundRobin(),
+      ModifyConsumerStream(w1, first2),
+      nc,
+      ModifyConsumerStream(w2, first2))
+  if output := fmt.Sprintf("%v %v", w1.values, w2.values); output != "[0 2] [1 3]" {
+    t.Errorf("Expected [0 2] [1 3] got %v", output)
+  }
+  if !nc.completed {
+    t.Error("Distribute returned before workers completed.")
+  }
+  var result int
+  if !s.Next(&result) || result > 6 {
+    t.Errorf("Expected Distribute to stop reading got %v", result)
+  }
+}
+
+func TestDistributeNoWorkers(t *testing.T) {
+  s := CountFrom(7, 1)
+  Distribute(s, new(int), nil, LeastBusy())
+  var result int
+  if !s.Next(&result) || result != 7 {
+    t.Errorf("Expected 7 got %v", result)
+  }
+}
+
+// collectingWorker collects the int values it consumes. If gate is not
+// nil, it waits on gate after each value until gate is closed.
+type collectingWorker struct {
+  gate chan struct{}
+  mu sync.Mutex
+  values []int
+}
+
+func (w *collectingWorker) Consume(s Stream) {
+  var x int
+  for s.Next(&x) {
+    w.mu.Lock()
+    w.values = append(w.values, x)
+    w.mu.Unlock()
+    if w.gate != nil {
+      <-w.gate
+    }
+  }
+}
+
+func (w *collectingWorker) count() int {
+  w.mu.Lock()
+  defer w.mu.Unlock()
+  return len(w.values)
+}
+
+func asConsumers(workers []*collectingWorker) []Consumer {
+  result := make([]Consumer, len(workers))
+  for i := range workers {
+    result[i] = workers[i]
+  }
+  return result
+}
+
+type txn struct {
+  Account string
+  Seq int
+}
+
+type txnWorker struct {
+  entries []txn
+}
+
+func (w *txnWorker) Consume(s Stream) {
+  AppendValues(s, &w.entries)
+}
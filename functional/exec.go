@@ -0,0 +1,151 @@
+package functional
+
+import (
+  "bufio"
+  "errors"
+  "io"
+  "os"
+  "os/exec"
+  "syscall"
+)
+
+// ExecStream starts cmd and returns a Generator of U that emits the values
+// cmd writes to its standard output. If in, a Stream of T, is not nil,
+// ExecStream writes the values of in to cmd's standard input on a separate
+// goroutine, closing standard input when in is exhausted. inPtr
+// is a *T that receives the values from in. encode converts each T value to
+// the bytes written to cmd. decode reads the next U value from cmd's
+// standard output storing it at ptr, a *U; decode returns io.EOF when
+// there are no more values. When there are no more values, the Generator
+// waits for cmd to exit; if cmd fails, the Generator's Err method, from
+// ErrStream, reports the error, usually an *exec.ExitError. Closing the
+// Generator before it is exhausted kills cmd. Neither Next nor Close waits
+// for the goroutine writing in. If cmd exits while that goroutine waits on
+// in, the goroutine stops at the next value of in, so in may still be read
+// after Close returns and must not be used elsewhere. To stop it sooner,
+// close in if it allows concurrent Close as described for Generator.
+func ExecStream(
+    cmd *exec.Cmd,
+    in Stream,
+    inPtr interface{},
+    encode func(ptr interface{}) []byte,
+    decode func(r *bufio.Reader, ptr interface{}) error) (Generator, error) {
+  stdout, err := cmd.StdoutPipe()
+  if err != nil {
+    return nil, err
+  }
+  var stdin io.WriteCloser
+  if in != nil {
+    if stdin, err = cmd.StdinPipe(); err != nil {
+      return nil, err
+    }
+  }
+  if err = cmd.Start(); err != nil {
+    return nil, err
+  }
+  g := &execGenerator{cmd: cmd, stdout: bufio.NewReader(stdout), decode: decode}
+  if stdin != nil {
+    g.writeDone = make(chan error, 1)
+    go writeStream(stdin, in, inPtr, encode, g.writeDone)
+  }
+  return g, nil
+}
+
+// DecodeLine is a decode function for ExecStream that reads a line of
+// text into ptr, a *string. Lines are separated by either "\n" or "\r\n".
+func DecodeLine(r *bufio.Reader, ptr interface{}) error {
+  line, err := r.ReadString('\n')
+  if err == io.EOF && line != "" {
+    err = nil
+  }
+  if err != nil {
+    return err
+  }
+  if len(line) > 0 && line[len(line) - 1] == '\n' {
+    line = line[:len(line) - 1]
+  }
+  *ptr.(*string) = string(dropCR([]byte(line)))
+  return nil
+}
+
+type execGenerator struct {
+  cmd *exec.Cmd
+  stdout *bufio.Reader
+  decode func(r *bufio.Reader, ptr interface{}) error
+  writeDone chan error
+  err error
+  done bool
+}
+
+func (g *execGenerator) Next(ptr interface{}) bool {
+  if g.done {
+    return false
+  }
+  err := g.decode(g.stdout, ptr)
+  if err == nil {
+    return true
+  }
+  g.done = true
+  if err != io.EOF {
+    g.cmd.Process.Kill()
+  }
+  waitErr := g.cmd.Wait()
+  writeErr := g.writerErr()
+  switch {
+  case err != io.EOF:
+    g.err = err
+  case waitErr != nil:
+    g.err = waitErr
+  case writeErr != nil && !isClosedPipe(writeErr):
+    g.err = writeErr
+  }
+  return false
+}
+
+func (g *execGenerator) Err() error {
+  return g.err
+}
+
+func (g *execGenerator) Close() error {
+  if g.done {
+    return nil
+  }
+  g.done = true
+  g.cmd.Process.Kill()
+  g.cmd.Wait()
+  return nil
+}
+
+// writerErr returns the error from the goroutine writing in if it has
+// finished. Once cmd exits, that goroutine fails as soon as it writes, so
+// writerErr does not wait for it in case it is waiting on in.
+func (g *execGenerator) writerErr() error {
+  select {
+  case err := <-g.writeDone:
+    return err
+  default:
+    return nil
+  }
+}
+
+func writeStream(
+    w io.WriteCloser,
+    s Stream,
+    ptr interface{},
+    encode func(ptr interface{}) []byte,
+    done chan<- error) {
+  var err error
+  for err == nil && s.Next(ptr) {
+    _, err = w.Write(encode(ptr))
+  }
+  if cerr := w.Close(); err == nil {
+    err = cerr
+  }
+  done <- err
+}
+
+// isClosedPipe returns true if err means the other end of a pipe went away
+// which happens when a child process exits without reading all its input.
+func isClosedPipe(err error) bool {
+  return errors.Is(err, syscall.EPIPE) || errors.Is(err, os.ErrClosed) || errors.Is(err, io.ErrClosedPipe)
+}
@@ -0,0 +1,176 @@
+package functional
+
+import (
+    "bufio"
+    "errors"
+    "fmt"
+    "io"
+    "os"
+    "os/exec"
+    "strings"
+    "testing"
+)
+
+const (
+  kHelperEnv = "FUNCTIONAL_TEST_HELPER"
+)
+
+// TestMain lets the test binary double as the child process for the
+// ExecStream tests.
+func TestMain(m *testing.M) {
+  switch os.Getenv(kHelperEnv) {
+  case "":
+    os.Exit(m.Run())
+  case "upper":
+    scanner := bufio.NewScanner(os.Stdin)
+    for scanner.Scan() {
+      fmt.Println(strings.ToUpper(scanner.Text()))
+    }
+    os.Exit(0)
+  case "fail":
+    fmt.Println("partial")
+    os.Exit(3)
+  case "forever":
+    for i := 0; ; i++ {
+      if _, err := fmt.Println(i); err != nil {
+        os.Exit(1)
+      }
+    }
+  }
+  os.Exit(2)
+}
+
+func TestExecStream(t *testing.T) {
+  in := NewStreamFromValues([]string{"Now is", "the time", "for all"})
+  g, err := ExecStream(helperCommand("upper"), in, new(string), appendNewLine, DecodeLine)
+  if err != nil {
+    t.Fatalf("Got error %v", err)
+  }
+  var results []string
+  AppendValues(g, &results)
+  if output := strings.Join(results, ","); output != "NOW IS,THE TIME,FOR ALL" {
+    t.Errorf("Expected 'NOW IS,THE TIME,FOR ALL' got '%v'", output)
+  }
+  if err := g.(ErrStream).Err(); err != nil {
+    t.Errorf("Expected no error got %v", err)
+  }
+  g.Close()
+}
+
+func TestExecStreamLarge(t *testing.T) {
+  in := Slice(Count(), 0, 20000)
+  intToLine := func(ptr interface{}) []byte {
+    return []byte(fmt.Sprintf("x%d\n", *ptr.(*int)))
+  }
+  g, err := ExecStream(helperCommand("upper"), in, new(int), intToLine, DecodeLine)
+  if err != nil {
+    t.Fatalf("Got error %v", err)
+  }
+  var results []string
+  AppendValues(g, &results)
+  if len(results) != 20000 || results[19999] != "X19999" {
+    t.Errorf("Expected 20000 lines got %v", len(results))
+  }
+}
+
+func TestExecStreamExitStatus(t *testing.T) {
+  g, err := ExecStream(helperCommand("fail"), nil, nil, nil, DecodeLine)
+  if err != nil {
+    t.Fatalf("Got error %v", err)
+  }
+  var results []string
+  AppendValues(g, &results)
+  if output := strings.Join(results, ","); output != "partial" {
+    t.Errorf("Expected 'partial' got '%v'", output)
+  }
+  var exitErr *exec.ExitError
+  if err := g.(ErrStream).Err(); !errors.As(err, &exitErr) || exitErr.ExitCode() != 3 {
+    t.Errorf("Expected exit status 3 got %v", err)
+  }
+}
+
+func TestExecStreamClose(t *testing.T) {
+  g, err := ExecStream(helperCommand("forever"), Count(), new(int), appendNewLineInt, DecodeLine)
+  if err != nil {
+    t.Fatalf("Got error %v", err)
+  }
+  var results []string
+  AppendValues(Slice(g, 0, 3), &results)
+  if output := strings.Join(results, ","); output != "0,1,2" {
+    t.Errorf("Expected '0,1,2' got '%v'", output)
+  }
+  if err := g.Close(); err != nil {
+    t.Errorf("Expected no error got %v", err)
+  }
+  if g.Next(new(string)) {
+    t.Error("Expected closed Generator to be empty.")
+  }
+}
+
+func TestExecStreamBlockedInput(t *testing.T) {
+  in := &blockingGenerator{unblock: make(chan struct{})}
+  defer in.Close()
+  g, err := ExecStream(helperCommand("forever"), in, new(int), appendNewLineInt, DecodeLine)
+  if err != nil {
+    t.Fatalf("Got error %v", err)
+  }
+  var results []string
+  AppendValues(Slice(g, 0, 3), &results)
+  if output := strings.Join(results, ","); output != "0,1,2" {
+    t.Errorf("Expected '0,1,2' got '%v'", output)
+  }
+  if err := g.Close(); err != nil {
+    t.Errorf("Expected no error got %v", err)
+  }
+}
+
+func TestExecStreamExitWithBlockedInput(t *testing.T) {
+  in := &blockingGenerator{unblock: make(chan struct{})}
+  defer in.Close()
+  g, err := ExecStream(helperCommand("fail"), in, new(int), appendNewLineInt, DecodeLine)
+  if err != nil {
+    t.Fatalf("Got error %v", err)
+  }
+  var results []string
+  AppendValues(g, &results)
+  if output := strings.Join(results, ","); output != "partial" {
+    t.Errorf("Expected 'partial' got '%v'", output)
+  }
+  var exitErr *exec.ExitError
+  if err := g.(ErrStream).Err(); !errors.As(err, &exitErr) || exitErr.ExitCode() != 3 {
+    t.Errorf("Expected exit status 3 got %v", err)
+  }
+}
+
+func TestExecStreamBadCommand(t *testing.T) {
+  cmd := exec.Command("/nonexistent/command")
+  if _, err := ExecStream(cmd, nil, nil, nil, DecodeLine); err == nil {
+    t.Error("Expected error starting command.")
+  }
+}
+
+func TestDecodeLine(t *testing.T) {
+  r := bufio.NewReader(strings.NewReader("a\r\nb\n\nc"))
+  var results []string
+  var line string
+  var err error
+  for err = DecodeLine(r, &line); err == nil; err = DecodeLine(r, &line) {
+    results = append(results, line)
+  }
+  if err != io.EOF {
+    t.Errorf("Expected io.EOF got %v", err)
+  }
+  if output := strings.Join(results, ","); output != "a,b,,c" {
+    t.Errorf("Expected 'a,b,,c' got '%v'", output)
+  }
+}
+
+func helperCommand(name string) *exec.Cmd {
+  cmd := exec.Command(os.Args[0])
+  cmd.Env = append(os.Environ(), kHelperEnv + "=" + name)
+  return cmd
+}
+
+func appendNewLineInt(ptr interface{}) []byte {
+  return []byte(fmt.Sprintf("%d\n", *ptr.(*int)))
+}
@@ -0,0 +1,150 @@
+package functional
+
+import (
+  "errors"
+  "sync"
+)
+
+// Tagged is a value from FanInTagged along with the index of the Generator
+// it came from.
+type Tagged struct {
+  // Index is the index of the Generator that emitted the value.
+  Index int
+  // Ptr is a *T that receives the value. The caller sets Ptr before
+  // calling Next.
+  Ptr interface{}
+}
+
+// FanIn returns a Generator of T that emits the values of gens, each a
+// Generator of T, in the order they arrive. FanIn reads each Generator in
+// gens on its own goroutine so that slow Generators do not hold up the
+// others. c is a Creater of T that creates the value each goroutine reads
+// into. copier is a Copier of T that copies values to the caller; if nil,
+// regular assignment is used. Each Generator in gens is closed once it is
+// exhausted or once the returned Generator is closed. Since closing the
+// returned Generator closes gens while FanIn may be reading them, gens must
+// allow concurrent Close as described for Generator. The returned
+// Generator's Err method, from ErrStream, joins the errors of any Generators
+// in gens that are ErrStreams.
+func FanIn(c Creater, copier Copier, gens ...Generator) Generator {
+  return newFanIn(c, copier, false, gens)
+}
+
+// FanInTagged works like FanIn except that the returned Generator is a
+// Generator of Tagged so that callers know which Generator each value came
+// from.
+func FanInTagged(c Creater, copier Copier, gens ...Generator) Generator {
+  return newFanIn(c, copier, true, gens)
+}
+
+func newFanIn(c Creater, copier Copier, tagged bool, gens []Generator) Generator {
+  if copier == nil {
+    copier = assignCopier
+  }
+  f := &fanIn{
+      copier: copier,
+      tagged: tagged,
+      values: make(chan fanInValue),
+      done: make(chan struct{}),
+      gens: gens,
+      errs: make([]error, len(gens)),
+      closeOnces: make([]sync.Once, len(gens)),
+      closeErrs: make([]error, len(gens))}
+  f.wg.Add(len(gens))
+  for i := range gens {
+    go f.read(i, c())
+  }
+  go func() {
+    f.wg.Wait()
+    close(f.values)
+  }()
+  return f
+}
+
+type fanInValue struct {
+  index int
+  ptr interface{}
+  // taken receives when ptr may be re-used.
+  taken chan<- struct{}
+}
+
+type fanIn struct {
+  copier Copier
+  tagged bool
+  values chan fanInValue
+  done chan struct{}
+  closeOnce sync.Once
+  wg sync.WaitGroup
+  gens []Generator
+  errs []error
+  // closeOnces ensures each Generator in gens is closed once.
+  closeOnces []sync.Once
+  closeErrs []error
+  exhausted bool
+}
+
+func (f *fanIn) Next(ptr interface{}) bool {
+  select {
+  case <-f.done:
+    return false
+  default:
+  }
+  value, ok := <-f.values
+  if !ok {
+    f.exhausted = true
+    return false
+  }
+  if f.tagged {
+    t := ptr.(*Tagged)
+    t.Index = value.index
+    f.copier(value.ptr, t.Ptr)
+  } else {
+    f.copier(value.ptr, ptr)
+  }
+  value.taken <- struct{}{}
+  return true
+}
+
+func (f *fanIn) Err() error {
+  if !f.exhausted {
+    return nil
+  }
+  return errors.Join(f.errs...)
+}
+
+func (f *fanIn) Close() error {
+  f.closeOnce.Do(func() {
+    close(f.done)
+    for i := range f.gens {
+      f.closeInput(i)
+    }
+    for value := range f.values {
+      value.taken <- struct{}{}
+    }
+  })
+  return errors.Join(f.closeErrs...)
+}
+
+func (f *fanIn) closeInput(index int) {
+  f.closeOnces[index].Do(func() {
+    f.closeErrs[index] = f.gens[index].Close()
+  })
+}
+
+func (f *fanIn) read(index int, ptr interface{}) {
+  defer f.wg.Done()
+  defer f.closeInput(index)
+  g := f.gens[index]
+  taken := make(chan struct{})
+  for g.Next(ptr) {
+    select {
+    case <-f.done:
+      return
+    case f.values <- fanInValue{index, ptr, taken}:
+    }
+    <-taken
+  }
+  if es, ok := g.(ErrStream); ok {
+    f.errs[index] = es.Err()
+  }
+}
@@ -0,0 +1,205 @@
+package functional
+
+import (
+    "errors"
+    "fmt"
+    "sort"
+    "strings"
+    "testing"
+    "testing/iotest"
+    "time"
+)
+
+func TestFanIn(t *testing.T) {
+  g1 := &closeCountingGenerator{Stream: xrange(0, 50)}
+  g2 := &closeCountingGenerator{Stream: xrange(100, 150)}
+  g3 := &closeCountingGenerator{Stream: NilStream()}
+  g := FanIn(func() interface{} { return new(int) }, nil, g1, g2, g3)
+  var results []int
+  AppendValues(g, &results)
+  sort.Ints(results)
+  if len(results) != 100 || results[49] != 49 || results[50] != 100 {
+    t.Errorf("Expected 100 values got %v", results)
+  }
+  if err := g.(ErrStream).Err(); err != nil {
+    t.Errorf("Expected no error got %v", err)
+  }
+  if err := g.Close(); err != nil {
+    t.Errorf("Expected no error got %v", err)
+  }
+  if g1.closed != 1 || g2.closed != 1 || g3.closed != 1 {
+    t.Error("Expected each input closed once.")
+  }
+}
+
+func TestFanInKeepsOrderPerInput(t *testing.T) {
+  g := FanInTagged(
+      func() interface{} { return new(int) },
+      nil,
+      StreamToGenerator(xrange(0, 20), nopCloser{}),
+      StreamToGenerator(xrange(0, 20), nopCloser{}))
+  var x int
+  tagged := Tagged{Ptr: &x}
+  next := []int{0, 0}
+  for g.Next(&tagged) {
+    if x != next[tagged.Index] {
+      t.Fatalf("Expected %v from %v got %v", next[tagged.Index], tagged.Index, x)
+    }
+    next[tagged.Index]++
+  }
+  if next[0] != 20 || next[1] != 20 {
+    t.Errorf("Expected 20 values from each got %v", next)
+  }
+}
+
+func TestFanInSlowInput(t *testing.T) {
+  slow := &gatedGenerator{gate: make(chan struct{})}
+  g := FanIn(
+      func() interface{} { return new(int) },
+      nil,
+      slow,
+      StreamToGenerator(xrange(0, 3), nopCloser{}))
+  var results []int
+  AppendValues(Slice(g, 0, 3), &results)
+  if output := fmt.Sprintf("%v", results); output != "[0 1 2]" {
+    t.Errorf("Expected [0 1 2] got %v", output)
+  }
+  close(slow.gate)
+  var x int
+  if !g.Next(&x) || x != 42 {
+    t.Errorf("Expected 42 got %v", x)
+  }
+  g.Close()
+  if !slow.closed {
+    t.Error("Expected slow input closed.")
+  }
+}
+
+func TestFanInClose(t *testing.T) {
+  g1 := &closeCountingGenerator{Stream: Count()}
+  g2 := &closeCountingGenerator{Stream: Count(), closeErr: errors.New("close failed")}
+  g := FanIn(func() interface{} { return new(int) }, nil, g1, g2)
+  var results []int
+  AppendValues(Slice(g, 0, 5), &results)
+  if err := g.Close(); err == nil || err.Error() != "close failed" {
+    t.Errorf("Expected 'close failed' got %v", err)
+  }
+  if g1.closed != 1 || g2.closed != 1 {
+    t.Error("Expected each input closed once.")
+  }
+  if g.Next(new(int)) {
+    t.Error("Expected closed Generator to be empty.")
+  }
+}
+
+func TestFanInError(t *testing.T) {
+  bad := ReadLines(iotest.TimeoutReader(iotest.OneByteReader(strings.NewReader("a\nb\n"))))
+  g := FanIn(
+      func() interface{} { return new(string) },
+      nil,
+      errStreamGenerator{bad},
+      StreamToGenerator(NewStreamFromValues([]string{"x"}), nopCloser{}))
+  defer g.Close()
+  var results []string
+  AppendValues(g, &results)
+  sort.Strings(results)
+  if output := strings.Join(results, ","); output != "a,x" {
+    t.Errorf("Expected 'a,x' got '%v'", output)
+  }
+  if err := g.(ErrStream).Err(); !errors.Is(err, iotest.ErrTimeout) {
+    t.Errorf("Expected timeout error got %v", err)
+  }
+}
+
+// closeCountingGenerator counts how many times it is closed.
+type closeCountingGenerator struct {
+  Stream
+  closed int
+  closeErr error
+}
+
+func (g *closeCountingGenerator) Close() error {
+  g.closed++
+  return g.closeErr
+}
+
+// errStreamGenerator is a Generator that keeps the Err method of its
+// ErrStream.
+type errStreamGenerator struct {
+  ErrStream
+}
+
+func (g errStreamGenerator) Close() error {
+  return nil
+}
+
+// gatedGenerator emits 42 once gate is closed.
+type gatedGenerator struct {
+  gate chan struct{}
+  emitted bool
+  closed bool
+}
+
+func (g *gatedGenerator) Next(ptr interface{}) bool {
+  if g.emitted {
+    return false
+  }
+  <-g.gate
+  *ptr.(*int) = 42
+  g.emitted = true
+  return true
+}
+
+func (g *gatedGenerator) Close() error {
+  g.closed = true
+  return nil
+}
+
+func TestFanInCloseWhileWaiting(t *testing.T) {
+  name := writeTempFile(t, []byte("a\n"))
+  clock := newFakeClock()
+  follow, err := FollowLines(name, &FollowOptions{Interval: time.Second, Clock: clock})
+  if err != nil {
+    t.Fatalf("Got error %v", err)
+  }
+  g := FanIn(
+      func() interface{} { return new(string) },
+      nil,
+      follow,
+      StreamToGenerator(NewStreamFromValues([]string{"x"}), nopCloser{}))
+  var results []string
+  AppendValues(Slice(g, 0, 2), &results)
+  sort.Strings(results)
+  if output := strings.Join(results, ","); output != "a,x" {
+    t.Errorf("Expected 'a,x' got '%v'", output)
+  }
+  // follow waits for more lines.
+  clock.WaitForWaiters(1)
+  if err := g.Close(); err != nil {
+    t.Errorf("Expected no error got %v", err)
+  }
+  if follow.Next(new(string)) {
+    t.Error("Expected Close to close input.")
+  }
+}
+
+func TestFanInCloseWhileGeneratorWaits(t *testing.T) {
+  values := make(chan int)
+  asked := make(chan struct{}, 10)
+  g := FanIn(
+      func() interface{} { return new(int) },
+      nil,
+      chanEmitter(values, asked),
+      StreamToGenerator(xrange(0, 2), nopCloser{}))
+  var results []int
+  AppendValues(Slice(g, 0, 2), &results)
+  if output := fmt.Sprintf("%v", results); output != "[0 1]" {
+    t.Errorf("Expected [0 1] got %v", output)
+  }
+  // The slow input waits for a value.
+  <-asked
+  if err := g.Close(); err != nil {
+    t.Errorf("Expected no error got %v", err)
+  }
+  close(values)
+}
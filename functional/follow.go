@@ -0,0 +1,210 @@
+package functional
+
+import (
+  "bytes"
+  "io"
+  "os"
+  "sync"
+  "time"
+)
+
+const (
+  kDefaultFollowInterval = time.Second
+  kFollowChunkSize = 32 * 1024
+)
+
+// FollowOptions are the options for FollowLines.
+type FollowOptions struct {
+  // Interval is how often to check the file for new data. If zero, one
+  // second is used.
+  Interval time.Duration
+  // Clock is used for waiting between checks. If nil, SystemClock is used.
+  Clock Clock
+  // FromEnd, if true, skips the lines already in the file when FollowLines
+  // is called.
+  FromEnd bool
+}
+
+// FollowLines returns a Generator of string that emits the lines of the
+// named file, like "tail -f". Lines are separated by either "\n" or
+// "\r\n". When the Generator reaches the end of the file, Next waits for
+// more lines to be appended rather than returning false. If the file is
+// truncated, the Generator starts again from the beginning. If the file is
+// renamed and a new file created in its place, the Generator finishes the
+// old file and continues with the new one. opts may be nil. Unlike other
+// Generators, the returned Generator may be closed from another goroutine to
+// stop a Next call that is waiting for new lines. If reading the file fails,
+// Next returns false and the Generator's Err method, from ErrStream,
+// reports the error.
+func FollowLines(path string, opts *FollowOptions) (Generator, error) {
+  if opts == nil {
+    opts = &FollowOptions{}
+  }
+  file, err := os.Open(path)
+  if err != nil {
+    return nil, err
+  }
+  g := &followGenerator{
+      path: path,
+      interval: opts.Interval,
+      clock: clockOrDefault(opts.Clock),
+      file: file,
+      done: make(chan struct{})}
+  if g.interval <= 0 {
+    g.interval = kDefaultFollowInterval
+  }
+  if opts.FromEnd {
+    if g.offset, err = file.Seek(0, io.SeekEnd); err != nil {
+      file.Close()
+      return nil, err
+    }
+  }
+  return g, nil
+}
+
+type followGenerator struct {
+  path string
+  interval time.Duration
+  clock Clock
+  mu sync.Mutex
+  file *os.File
+  offset int64
+  pending []byte
+  chunk []byte
+  done chan struct{}
+  closeOnce sync.Once
+  err error
+}
+
+func (g *followGenerator) Next(ptr interface{}) bool {
+  p := ptr.(*string)
+  g.mu.Lock()
+  defer g.mu.Unlock()
+  for g.file != nil && g.err == nil {
+    if line, ok := g.nextLine(); ok {
+      *p = line
+      return true
+    }
+    n, err := g.read()
+    if err != nil {
+      g.err = err
+      return false
+    }
+    if n > 0 {
+      continue
+    }
+    rotated, truncated, err := g.checkFile()
+    if err != nil {
+      g.err = err
+      return false
+    }
+    if rotated && len(g.pending) > 0 {
+      *p = string(dropCR(g.pending))
+      g.pending = g.pending[:0]
+      return true
+    }
+    if !rotated && !truncated && !g.wait() {
+      return false
+    }
+  }
+  return false
+}
+
+func (g *followGenerator) Err() error {
+  g.mu.Lock()
+  defer g.mu.Unlock()
+  return g.err
+}
+
+func (g *followGenerator) Close() error {
+  g.closeOnce.Do(func() { close(g.done) })
+  g.mu.Lock()
+  defer g.mu.Unlock()
+  if g.file == nil {
+    return nil
+  }
+  err := g.file.Close()
+  g.file = nil
+  return err
+}
+
+func (g *followGenerator) nextLine() (string, bool) {
+  i := bytes.IndexByte(g.pending, '\n')
+  if i < 0 {
+    return "", false
+  }
+  line := string(dropCR(g.pending[:i]))
+  g.pending = g.pending[i + 1:]
+  return line, true
+}
+
+func (g *followGenerator) read() (int, error) {
+  if g.chunk == nil {
+    g.chunk = make([]byte, kFollowChunkSize)
+  }
+  n, err := g.file.Read(g.chunk)
+  g.pending = append(g.pending, g.chunk[:n]...)
+  g.offset += int64(n)
+  if err == io.EOF {
+    err = nil
+  }
+  return n, err
+}
+
+// checkFile checks whether the followed file was replaced or truncated.
+// If it was replaced, checkFile switches to the new file. If it was
+// truncated, checkFile rewinds to the beginning.
+func (g *followGenerator) checkFile() (rotated, truncated bool, err error) {
+  current, err := g.file.Stat()
+  if err != nil {
+    return false, false, err
+  }
+  latest, err := os.Stat(g.path)
+  if err != nil {
+    // The file may be missing briefly while it is being rotated.
+    return false, false, nil
+  }
+  if !os.SameFile(current, latest) {
+    file, err := os.Open(g.path)
+    if err != nil {
+      return false, false, nil
+    }
+    g.file.Close()
+    g.file = file
+    g.offset = 0
+    return true, false, nil
+  }
+  if latest.Size() < g.offset {
+    if _, err := g.file.Seek(0, io.SeekStart); err != nil {
+      return false, false, err
+    }
+    g.offset = 0
+    g.pending = g.pending[:0]
+    return false, true, nil
+  }
+  return false, false, nil
+}
+
+// wait waits for the poll interval to elapse. wait releases g.mu while
+// waiting so that Close may be called. wait returns false if g was closed.
+func (g *followGenerator) wait() bool {
+  g.mu.Unlock()
+  select {
+  case <-g.clock.After(g.interval):
+  case <-g.done:
+  }
+  g.mu.Lock()
+  select {
+  case <-g.done:
+    return false
+  default:
+    return true
+  }
+}
+
+func dropCR(b []byte) []byte {
+  if len(b) > 0 && b[len(b) - 1] == '\r' {
+    return b[:len(b) - 1]
+  }
+  return b
+}
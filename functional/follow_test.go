@@ -0,0 +1,160 @@
+package functional
+
+import (
+    "os"
+    "path/filepath"
+    "testing"
+    "time"
+)
+
+func TestFollowLines(t *testing.T) {
+  name := writeTempFile(t, []byte("a\r\nb\npar"))
+  c := newFakeClock()
+  g, err := FollowLines(name, &FollowOptions{Interval: time.Second, Clock: c})
+  if err != nil {
+    t.Fatalf("Got error %v", err)
+  }
+  defer g.Close()
+  expectNextLine(t, g, "a")
+  expectNextLine(t, g, "b")
+  ch := nextLineAsync(g)
+  c.WaitForWaiters(1)
+  appendToFile(t, name, "tial\nc\n")
+  c.Advance(time.Second)
+  if line := <-ch; line != "partial" {
+    t.Errorf("Expected 'partial' got '%v'", line)
+  }
+  expectNextLine(t, g, "c")
+}
+
+func TestFollowLinesFromEnd(t *testing.T) {
+  name := writeTempFile(t, []byte("a\nb\n"))
+  g, err := FollowLines(name, &FollowOptions{FromEnd: true, Clock: newFakeClock()})
+  if err != nil {
+    t.Fatalf("Got error %v", err)
+  }
+  defer g.Close()
+  appendToFile(t, name, "c\n")
+  expectNextLine(t, g, "c")
+}
+
+func TestFollowLinesTruncate(t *testing.T) {
+  name := writeTempFile(t, []byte("aaa\nbbb\n"))
+  g, err := FollowLines(name, &FollowOptions{Clock: newFakeClock()})
+  if err != nil {
+    t.Fatalf("Got error %v", err)
+  }
+  defer g.Close()
+  expectNextLine(t, g, "aaa")
+  expectNextLine(t, g, "bbb")
+  if err := os.WriteFile(name, []byte("x\n"), 0644); err != nil {
+    t.Fatalf("Got error %v", err)
+  }
+  expectNextLine(t, g, "x")
+}
+
+func TestFollowLinesRotate(t *testing.T) {
+  name := writeTempFile(t, []byte("a\n"))
+  c := newFakeClock()
+  g, err := FollowLines(name, &FollowOptions{Interval: time.Second, Clock: c})
+  if err != nil {
+    t.Fatalf("Got error %v", err)
+  }
+  defer g.Close()
+  expectNextLine(t, g, "a")
+  rotated := filepath.Join(filepath.Dir(name), "rotated")
+  if err := os.Rename(name, rotated); err != nil {
+    t.Fatalf("Got error %v", err)
+  }
+  appendToFile(t, rotated, "b")
+  if err := os.WriteFile(name, []byte("c\n"), 0644); err != nil {
+    t.Fatalf("Got error %v", err)
+  }
+  expectNextLine(t, g, "b")
+  expectNextLine(t, g, "c")
+}
+
+func TestFollowLinesRotateMissing(t *testing.T) {
+  name := writeTempFile(t, []byte("a\n"))
+  c := newFakeClock()
+  g, err := FollowLines(name, &FollowOptions{Interval: time.Second, Clock: c})
+  if err != nil {
+    t.Fatalf("Got error %v", err)
+  }
+  defer g.Close()
+  expectNextLine(t, g, "a")
+  if err := os.Rename(name, filepath.Join(filepath.Dir(name), "rotated")); err != nil {
+    t.Fatalf("Got error %v", err)
+  }
+  ch := nextLineAsync(g)
+  c.WaitForWaiters(1)
+  if err := os.WriteFile(name, []byte("b\n"), 0644); err != nil {
+    t.Fatalf("Got error %v", err)
+  }
+  c.Advance(time.Second)
+  if line := <-ch; line != "b" {
+    t.Errorf("Expected 'b' got '%v'", line)
+  }
+}
+
+func TestFollowLinesClose(t *testing.T) {
+  name := writeTempFile(t, []byte("a\n"))
+  c := newFakeClock()
+  g, err := FollowLines(name, &FollowOptions{Clock: c})
+  if err != nil {
+    t.Fatalf("Got error %v", err)
+  }
+  expectNextLine(t, g, "a")
+  done := make(chan bool)
+  go func() {
+    done <- g.Next(new(string))
+  }()
+  c.WaitForWaiters(1)
+  if err := g.Close(); err != nil {
+    t.Errorf("Expected no error on close got %v", err)
+  }
+  if <-done {
+    t.Error("Expected Next to return false after Close.")
+  }
+  if g.Next(new(string)) {
+    t.Error("Expected closed Generator to be empty.")
+  }
+  if err := g.(ErrStream).Err(); err != nil {
+    t.Errorf("Expected no error got %v", err)
+  }
+}
+
+func TestFollowLinesMissing(t *testing.T) {
+  if _, err := FollowLines(filepath.Join(t.TempDir(), "missing"), nil); err == nil {
+    t.Error("Expected error following missing file.")
+  }
+}
+
+func expectNextLine(t *testing.T, s Stream, expected string) {
+  t.Helper()
+  var line string
+  if !s.Next(&line) || line != expected {
+    t.Errorf("Expected '%v' got '%v'", expected, line)
+  }
+}
+
+func nextLineAsync(s Stream) <-chan string {
+  result := make(chan string, 1)
+  go func() {
+    var line string
+    s.Next(&line)
+    result <- line
+  }()
+  return result
+}
+
+func appendToFile(t *testing.T, name, contents string) {
+  f, err := os.OpenFile(name, os.O_APPEND | os.O_WRONLY, 0644)
+  if err != nil {
+    t.Fatalf("Got error %v", err)
+  }
+  defer f.Close()
+  if _, err := f.WriteString(contents); err != nil {
+    t.Fatalf("Got error %v", err)
+  }
+}
@@ -3,8 +3,8 @@ package functional
 
 import (
   "bufio"
-  "fmt"
   "io"
+  "math"
   "reflect"
 )
 
@@ -30,6 +30,14 @@ type Stream interface {
   Next(ptr interface{}) bool
 }
 
+// ErrStream is a Stream that can stop emitting values because of an error.
+type ErrStream interface {
+  Stream
+  // Err returns the error that caused Next to return false or nil if the
+  // end of the Stream was reached normally.
+  Err() error
+}
+
 // Tuple represents a tuple of values that Join emits
 type Tuple interface {
   // Ptrs returns a pointer to each field in the tuple.
@@ -149,23 +157,25 @@ func (g *Group) advance() bool {
 // if f returns false for a T value, then the corresponding U value is left
 // out of the returned stream. ptr is a *T providing storage for emitted values
 // from s. Clients need not pass f.Fast() to Map because Map calls Fast
-// internally.
-func Map(f Mapper, s Stream, ptr interface{}) Stream {
+// internally. Closing the returned Generator closes s if it is an
+// io.Closer.
+func Map(f Mapper, s Stream, ptr interface{}) Generator {
   ms, ok := s.(*mapStream)
   if ok {
-    return &mapStream{Compose(f, ms.mapper, newCreater(ptr)).Fast(), ms.stream, ms.ptr}
+    return &mapStream{Compose(f, ms.mapper, newCreater(ptr)).Fast(), ms.stream, ms.ptr, ms.streamCloser}
   }
-  return &mapStream{f.Fast(), s, ptr}
+  return &mapStream{f.Fast(), s, ptr, newStreamCloser(s)}
 }
 
 // Filter filters values from s, returning a new Stream of T.
-// f is a Filterer of T; s is a Stream of T.
-func Filter(f Filterer, s Stream) Stream {
+// f is a Filterer of T; s is a Stream of T. Closing the returned Generator
+// closes s if it is an io.Closer.
+func Filter(f Filterer, s Stream) Generator {
   fs, ok := s.(*filterStream)
   if ok {
-    return &filterStream{All(fs.filterer, f), fs.stream}
+    return &filterStream{All(fs.filterer, f), fs.stream, fs.streamCloser}
   }
-  return &filterStream{f, s}
+  return &filterStream{f, s, newStreamCloser(s)}
 }
 
 // Count returns an infinite Stream of int which emits all values beginning
@@ -182,25 +192,37 @@ func CountFrom(start, step int) Stream {
 
 // Slice returns a Stream that will emit elements in s starting at index start
 // and continuing to but not including index end. Indexes are 0 based. If end
-// is negative, it means go to the end of s.
-func Slice(s Stream, start int, end int) Stream {
-  return &sliceStream{s, start, end, 0}
+// is negative, it means go to the end of s. Closing the returned Generator
+// closes s if it is an io.Closer.
+func Slice(s Stream, start int, end int) Generator {
+  return &sliceStream{stream: s, start: start, end: end, streamCloser: newStreamCloser(s)}
+}
+
+// SliceAndClose works like Slice except that it closes g as soon as the
+// returned Generator stops emitting values, either because it reached end
+// or because g ran out. This frees the resources of g even if the caller
+// never closes the returned Generator.
+func SliceAndClose(g Generator, start int, end int) Generator {
+  return &sliceStream{stream: g, start: start, end: end, streamCloser: newStreamCloser(g), autoClose: true}
 }
 
 // Concat concatenates multiple Streams into one.
 // If x = (x1, x2, ...) and y = (y1, y2, ...) then
 // Concat(x, y) = (x1, x2, ..., y1, y2, ...)
-func Concat(s ...Stream) Stream {
-  return Flatten(NewStreamFromValues(s))
+// Closing the returned Generator closes each Stream in s that is an
+// io.Closer.
+func Concat(s ...Stream) Generator {
+  return StreamToGenerator(Flatten(NewStreamFromValues(s)), newStreamCloser(s...))
 }
 
 // Join uses multiple Streams to form a new Stream of Tuples.
 // if x = (x1, x2, ..) and y = (y1, y2, ...) then
 // Join(x, y) = ((x1, y1), (x2, y2), ...). 
-// The Stream Join returns quits emitting whenever one of the input Streams
-// runs out.
-func Join(s ...Stream) Stream {
-  return &joinStream{s}
+// The Generator Join returns quits emitting whenever one of the input
+// Streams runs out. Closing the returned Generator closes each Stream in s
+// that is an io.Closer.
+func Join(s ...Stream) Generator {
+  return &joinStream{streams: s, streamCloser: newStreamCloser(s...)}
 }
 
 // Cycle is deprecated. See CycleValues
@@ -245,28 +267,56 @@ func NilStream() Stream {
   return kNilStream
 }
 
-// Flatten converts a Stream of Stream of T into a Stream of T.
-func Flatten(s Stream) Stream {
-  return &flattenStream{stream: s}
+// Flatten converts a Stream of Stream of T into a Stream of T. Closing the
+// returned Generator closes s if it is an io.Closer.
+func Flatten(s Stream) Generator {
+  return &flattenStream{stream: s, streamCloser: newStreamCloser(s)}
 }
 
 // TakeWhile returns a Stream that emits the values in s until f is false.
-// f is a Filterer of T; s is a Stream of T.
-func TakeWhile(f Filterer, s Stream) Stream {
-  return &takeStream{f, s}
+// f is a Filterer of T; s is a Stream of T. Closing the returned Generator
+// closes s if it is an io.Closer.
+func TakeWhile(f Filterer, s Stream) Generator {
+  return &takeStream{filterer: f, stream: s, streamCloser: newStreamCloser(s)}
+}
+
+// TakeWhileAndClose works like TakeWhile except that it closes g as soon as
+// the returned Generator stops emitting values.
+func TakeWhileAndClose(f Filterer, g Generator) Generator {
+  return &takeStream{filterer: f, stream: g, streamCloser: newStreamCloser(g), autoClose: true}
 }
 
 // DropWhile returns a Stream that emits the values in s starting at the
 // first value where f is false. f is a Filterer of T; s is a Stream of T.
-func DropWhile(f Filterer, s Stream) Stream {
-  return &dropStream{f, s}
+// Closing the returned Generator closes s if it is an io.Closer.
+func DropWhile(f Filterer, s Stream) Generator {
+  return &dropStream{filterer: f, stream: s, streamCloser: newStreamCloser(s)}
 }
 
 // ReadLines returns the lines of text in r separated by either "\n" or "\r\n"
 // as a Stream of string. The emitted string types do not contain the
-// end of line characters.
-func ReadLines(r io.Reader) Stream {
-  return lineStream{bufio.NewReader(r)}
+// end of line characters. Lines may be of any length. If reading r fails,
+// the returned Stream stops and its Err method reports the error.
+func ReadLines(r io.Reader) ErrStream {
+  return ReadTokens(r, bufio.ScanLines, 0)
+}
+
+// ReadTokens returns the tokens in r as a Stream of string or []byte.
+// split breaks r into tokens e.g bufio.ScanWords, bufio.ScanRunes or a
+// custom bufio.SplitFunc. maxTokenSize is the maximum length of a token;
+// if maxTokenSize <= 0, tokens may be of any length. When calling Next on the
+// returned Stream, pass either a *string or a *[]byte. When passing a
+// *[]byte, Next re-uses the capacity of the slice it points to. If reading
+// r fails, if split returns an error, or if a token exceeds maxTokenSize,
+// the returned Stream stops and its Err method reports the error.
+func ReadTokens(r io.Reader, split bufio.SplitFunc, maxTokenSize int) ErrStream {
+  if maxTokenSize <= 0 {
+    maxTokenSize = math.MaxInt
+  }
+  scanner := bufio.NewScanner(r)
+  scanner.Buffer(nil, maxTokenSize)
+  scanner.Split(split)
+  return tokenStream{scanner}
 }
 
 // ReadRows returns the rows in a database table as a Stream of Tuple.
@@ -316,9 +366,11 @@ func GroupBy(s Stream, k KeyFunc, ptr interface{}, c Copier) Stream {
 // Deferred returns a Stream that emits the values from the Stream f returns.
 // f is not called until the first time Next is called on the returned stream.
 // In this way, the creation of a Stream can be deferred until the values
-// it emits are needed.
-func Deferred(f func() Stream) Stream {
-  return &deferredStream{f, nil}
+// it emits are needed. Closing the returned Generator closes the Stream f
+// returned if it is an io.Closer; once closed, the returned Generator never
+// calls f.
+func Deferred(f func() Stream) Generator {
+  return &deferredStream{f: f, streamCloser: newStreamCloser()}
 }
 
 // AppendValues evaluates s and appends each element in s to the slice that
@@ -453,6 +505,7 @@ type mapStream struct {
   mapper Mapper
   stream Stream
   ptr interface{} 
+  *streamCloser
 }
 
 func (s *mapStream) Next(ptr interface{}) bool {
@@ -467,6 +520,7 @@ func (s *mapStream) Next(ptr interface{}) bool {
 type filterStream struct {
   filterer Filterer
   stream Stream
+  *streamCloser
 }
 
 func (s *filterStream) Next(ptr interface{}) bool {
@@ -483,6 +537,8 @@ type sliceStream struct {
   start int
   end int
   index int
+  *streamCloser
+  autoClose bool
 }
 
 func (s *sliceStream) Next(ptr interface{}) bool {
@@ -493,12 +549,16 @@ func (s *sliceStream) Next(ptr interface{}) bool {
     }
     s.index++
   }
+  if s.autoClose {
+    s.Close()
+  }
   return false
 }
 
 type flattenStream struct {
   stream Stream
   current Stream
+  *streamCloser
 }
 
 func (s *flattenStream) Next(ptr interface{}) bool {
@@ -516,6 +576,7 @@ func (s *flattenStream) Next(ptr interface{}) bool {
 
 type joinStream struct {
   streams []Stream
+  *streamCloser
 }
 
 func (s *joinStream) Next(ptr interface{}) bool {
@@ -567,6 +628,8 @@ func (s *plainStream) Next(ptr interface{}) bool {
 type takeStream struct {
   filterer Filterer
   stream Stream
+  *streamCloser
+  autoClose bool
 }
 
 func (s *takeStream) Next(ptr interface{}) bool {
@@ -576,12 +639,17 @@ func (s *takeStream) Next(ptr interface{}) bool {
     }
     s.stream = nil
   }
+  s.stream = nil
+  if s.autoClose {
+    s.Close()
+  }
   return false
 }
 
 type dropStream struct {
   filterer Filterer
   stream Stream
+  *streamCloser
 }
 
 func (s *dropStream) Next(ptr interface{}) bool {
@@ -597,45 +665,25 @@ func (s *dropStream) Next(ptr interface{}) bool {
   return false
 }
 
-type lineStream struct {
-  *bufio.Reader
+type tokenStream struct {
+  *bufio.Scanner
 }
 
-func (s lineStream) Next(ptr interface{}) bool {
-  p := ptr.(*string)
-  line, isPrefix, err := s.ReadLine()
-  if err == io.EOF {
+func (s tokenStream) Next(ptr interface{}) bool {
+  if !s.Scan() {
     return false
   }
-  if err != nil {
-    panic(fmt.Sprintf("Received unexpected error %v", err))
-  }
-  if !isPrefix {
-    *p = string(line)
-    return true
+  switch p := ptr.(type) {
+  case *string:
+    *p = s.Text()
+  case *[]byte:
+    *p = append((*p)[:0], s.Bytes()...)
+  default:
+    panic("ptr must be a *string or *[]byte.")
   }
-  *p = s.readRestOfLine(line)
   return true
 }
 
-func (s lineStream) readRestOfLine(line []byte) string {
-  lines := [][]byte{copyBytes(line)}
-  for {
-    l, isPrefix, err := s.ReadLine()
-    if err == io.EOF {
-      break
-    }
-    if err != nil {
-      panic(fmt.Sprintf("Received unexpected error %v", err))
-    }
-    lines = append(lines, copyBytes(l))
-    if !isPrefix {
-      break
-    }
-  }
-  return string(byteFlatten(lines))
-}
-
 type rowStream struct {
   Rows
 }
@@ -686,11 +734,16 @@ func (g groupByStream) Next(ptr interface{}) bool {
 type deferredStream struct {
   f func() Stream
   s Stream
+  *streamCloser
 }
 
 func (d *deferredStream) Next(ptr interface{}) bool {
   if d.s == nil {
+    if d.closed {
+      return false
+    }
     d.s = d.f()
+    d.streams = []Stream{d.s}
   }
   return d.s.Next(ptr)
 }
@@ -884,25 +937,6 @@ func appendMapper(mappers []Mapper, creaters []Creater, m Mapper) int {
   return 1
 }
 
-func copyBytes(b []byte) []byte {
-  result := make([]byte, len(b))
-  copy(result, b)
-  return result
-}
-
-func byteFlatten(b [][]byte) []byte {
-  var l int
-  for i := range b {
-    l += len(b[i])
-  }
-  result := make([]byte, l)
-  n := 0
-  for i := range b {
-    n += copy(result[n:], b[i])
-  }
-  return result
-}
-
 func newCreater(ptr interface{}) Creater {
   return func() interface{} {
     return ptr
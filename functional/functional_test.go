@@ -1,10 +1,13 @@
 package functional
 
 import (
+    "bufio"
+    "bytes"
     "errors"
     "fmt"
     "strings"
     "testing"
+    "testing/iotest"
 )
 
 var (
@@ -370,6 +373,61 @@ func TestReadLinesLongLine2(t *testing.T) {
   }
 }
 
+func TestReadLinesCRLF(t *testing.T) {
+  s := ReadLines(strings.NewReader("Now is\r\nthe time\r\n\r\nfor all"))
+  var results []string
+  AppendValues(s, &results)
+  if output := strings.Join(results, ","); output != "Now is,the time,,for all" {
+    t.Errorf("Expected 'Now is,the time,,for all' got '%v'", output)
+  }
+  if s.Err() != nil {
+    t.Errorf("Expected no error got %v", s.Err())
+  }
+}
+
+func TestReadLinesError(t *testing.T) {
+  r := iotest.TimeoutReader(iotest.OneByteReader(strings.NewReader("ab\ncd")))
+  s := ReadLines(r)
+  var results []string
+  AppendValues(s, &results)
+  if s.Err() != iotest.ErrTimeout {
+    t.Errorf("Expected iotest.ErrTimeout got %v", s.Err())
+  }
+}
+
+func TestReadTokensWords(t *testing.T) {
+  s := ReadTokens(strings.NewReader(" Now is\tthe\n time "), bufio.ScanWords, 0)
+  var results []string
+  AppendValues(s, &results)
+  if output := strings.Join(results, ","); output != "Now,is,the,time" {
+    t.Errorf("Expected 'Now,is,the,time' got '%v'", output)
+  }
+}
+
+func TestReadTokensBytes(t *testing.T) {
+  s := ReadTokens(strings.NewReader("ab\x00\x00cde\x00"), scanNul, 0)
+  var results []string
+  var token []byte
+  for s.Next(&token) {
+    results = append(results, string(token))
+  }
+  if output := strings.Join(results, ","); output != "ab,,cde" {
+    t.Errorf("Expected 'ab,,cde' got '%v'", output)
+  }
+}
+
+func TestReadTokensTooLong(t *testing.T) {
+  s := ReadTokens(strings.NewReader("abc\nabcdef\nab\n"), bufio.ScanLines, 4)
+  var results []string
+  AppendValues(s, &results)
+  if output := strings.Join(results, ","); output != "abc" {
+    t.Errorf("Expected 'abc' got '%v'", output)
+  }
+  if s.Err() != bufio.ErrTooLong {
+    t.Errorf("Expected bufio.ErrTooLong got %v", s.Err())
+  }
+}
+
 func TestReadRows(t *testing.T) {
   rows := &fakeRows{ids: []int {3, 4}, names: []string{"foo", "bar"}}
   s := ReadRows(rows)
@@ -517,7 +575,7 @@ func TestInitPtrs(t *testing.T) {
 }
   
 func TestGroupBy(t *testing.T) {
-  s := Slice(CountFrom(6, 6), 0, 4)
+  var s Stream = Slice(CountFrom(6, 6), 0, 4)
   k := func(x interface{}) interface{} {
     p := x.(*int)
     return *p / 10
@@ -546,7 +604,7 @@ func TestGroupBy(t *testing.T) {
 }
 
 func TestGroupBySkipping(t *testing.T) {
-  s := Slice(CountFrom(6, 6), 0, 4)
+  var s Stream = Slice(CountFrom(6, 6), 0, 4)
   k := func(x interface{}) interface{} {
     p := x.(*int)
     return *p / 10
@@ -691,6 +749,142 @@ func TestCompose(t *testing.T) {
   }
 }  
 
+func TestCloseThroughCombinators(t *testing.T) {
+  g := &closeCountingGenerator{Stream: Count()}
+  even := NewFilterer(func(ptr interface{}) bool {
+    return *ptr.(*int) % 2 == 0
+  })
+  s := Slice(Map(squareIntInt32, Filter(even, g), new(int)), 0, 3)
+  var results []int32
+  AppendValues(s, &results)
+  if output := fmt.Sprintf("%v", results); output != "[0 4 16]" {
+    t.Errorf("Expected [0 4 16] got %v", output)
+  }
+  s.Close()
+  s.Close()
+  if g.closed != 1 {
+    t.Errorf("Expected source closed once got %v", g.closed)
+  }
+}
+
+func TestConcatClose(t *testing.T) {
+  g1 := &closeCountingGenerator{Stream: xrange(0, 2), closeErr: errors.New("first")}
+  g2 := &closeCountingGenerator{Stream: xrange(2, 4), closeErr: errors.New("second")}
+  s := Concat(g1, xrange(10, 11), g2, g1)
+  var results []int
+  AppendValues(s, &results)
+  if output := fmt.Sprintf("%v", results); output != "[0 1 10 2 3]" {
+    t.Errorf("Expected [0 1 10 2 3] got %v", output)
+  }
+  err := s.Close()
+  if err == nil || err.Error() != "first\nsecond" {
+    t.Errorf("Expected both close errors got %v", err)
+  }
+  if s.Close() != err {
+    t.Error("Expected second Close to return the same error.")
+  }
+  if g1.closed != 1 || g2.closed != 1 {
+    t.Errorf("Expected each source closed once got %v and %v", g1.closed, g2.closed)
+  }
+}
+
+func TestSliceAndClose(t *testing.T) {
+  g := &closeCountingGenerator{Stream: Count()}
+  s := SliceAndClose(g, 2, 5)
+  var results []int
+  AppendValues(s, &results)
+  if output := fmt.Sprintf("%v", results); output != "[2 3 4]" {
+    t.Errorf("Expected [2 3 4] got %v", output)
+  }
+  if g.closed != 1 {
+    t.Errorf("Expected source closed once got %v", g.closed)
+  }
+  s.Close()
+  if g.closed != 1 {
+    t.Errorf("Expected source closed once got %v", g.closed)
+  }
+}
+
+func TestTakeWhileAndClose(t *testing.T) {
+  g := &closeCountingGenerator{Stream: Count()}
+  lessThan3 := NewFilterer(func(ptr interface{}) bool {
+    return *ptr.(*int) < 3
+  })
+  s := TakeWhileAndClose(lessThan3, g)
+  var results []int
+  AppendValues(s, &results)
+  if output := fmt.Sprintf("%v", results); output != "[0 1 2]" {
+    t.Errorf("Expected [0 1 2] got %v", output)
+  }
+  if g.closed != 1 {
+    t.Errorf("Expected source closed once got %v", g.closed)
+  }
+  if s.Next(new(int)) {
+    t.Error("Expected no more values.")
+  }
+}
+
+func TestCloseThroughDropWhileJoinAndFlatten(t *testing.T) {
+  g1 := &closeCountingGenerator{Stream: Count()}
+  g2 := &closeCountingGenerator{Stream: NewStreamFromValues([]Stream{xrange(0, 2)})}
+  s := Join(DropWhile(lessThan(7), g1), Flatten(g2))
+  var results []pair
+  AppendValues(s, &results)
+  if output := fmt.Sprintf("%v", results); output != "[{7 0} {8 1}]" {
+    t.Errorf("Expected [{7 0} {8 1}] got %v", output)
+  }
+  s.Close()
+  s.Close()
+  if g1.closed != 1 || g2.closed != 1 {
+    t.Errorf("Expected each source closed once got %v and %v", g1.closed, g2.closed)
+  }
+}
+
+func TestDeferredClose(t *testing.T) {
+  g := &closeCountingGenerator{Stream: Count()}
+  calls := 0
+  f := func() Stream {
+    calls++
+    return g
+  }
+  s := Deferred(f)
+  s.Close()
+  if s.Next(new(int)) || calls != 0 {
+    t.Errorf("Expected f never called got %v calls", calls)
+  }
+  s = Deferred(f)
+  var x int
+  if !s.Next(&x) || x != 0 {
+    t.Errorf("Expected 0 got %v", x)
+  }
+  s.Close()
+  s.Close()
+  if calls != 1 || g.closed != 1 {
+    t.Errorf("Expected 1 call and 1 close got %v and %v", calls, g.closed)
+  }
+}
+
+func TestCloseOnGenerator(t *testing.T) {
+  g := NewGenerator(func(e Emitter) {
+    for i := 0; ; i++ {
+      ptr := e.EmitPtr()
+      if ptr == nil {
+        return
+      }
+      *ptr.(*int) = i
+    }
+  })
+  s := Slice(g, 3, -1)
+  var x int
+  if !s.Next(&x) || x != 3 {
+    t.Errorf("Expected 3 got %v", x)
+  }
+  s.Close()
+  if g.Next(&x) {
+    t.Error("Expected closing Slice to close the Generator.")
+  }
+}
+
 type pair struct {
   x int
   y int
@@ -740,6 +934,17 @@ func (f *fakeRowsError) Scan(args ...interface{}) error {
   return scanError
 }
   
+// scanNul is a bufio.SplitFunc for NUL terminated records.
+func scanNul(data []byte, atEOF bool) (advance int, token []byte, err error) {
+  if i := bytes.IndexByte(data, 0); i >= 0 {
+    return i + 1, data[:i], nil
+  }
+  if atEOF && len(data) > 0 {
+    return len(data), data, nil
+  }
+  return 0, nil, nil
+}
+
 type groupByResult struct {
   key int
   values []int
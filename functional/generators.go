@@ -1,10 +1,21 @@
 package functional
 
 import (
+  "errors"
   "io"
+  "reflect"
+  "sync"
 )
 
 // Generator is a Stream that can be closed.
+//
+// Generally, Close must not be called while a Next call is in progress.
+// Generators that allow concurrent Close permit it so that a Next call
+// waiting on I/O can be stopped from another goroutine; the Next call in
+// progress then returns false promptly. Generators from NewGenerator,
+// NewSendGenerator and FollowLines allow concurrent Close. Generators from
+// Buffered, FanIn, FanInTagged and Debounce allow it when the Streams they
+// read from do. Other Generators in this package do not.
 type Generator interface {
   Stream
   io.Closer
@@ -24,15 +35,71 @@ type Emitter interface {
   EmitPtr() interface{}
 }
 
+// SendGenerator is a Generator whose caller can send a value back to the
+// emitting function each time it asks for the next value.
+type SendGenerator interface {
+  Generator
+
+  // Send works like Next except that it also sends x to the emitting
+  // function. The emitting function receives x from the EmitPtrRecv call
+  // that emitted the previous value, so x is typically the caller's reply
+  // to that value. Calling Next is the same as calling Send with a nil x.
+  Send(ptr interface{}, x interface{}) bool
+}
+
+// SendEmitter is an Emitter that also receives the values that the caller
+// of the associated SendGenerator sends.
+type SendEmitter interface {
+  Emitter
+
+  // EmitPtrRecv works like EmitPtr except that it also returns the value
+  // the caller passed to Send when asking for the next value. Since
+  // EmitPtrRecv returns only after the caller has seen the value stored at
+  // the previous result of EmitPtrRecv, x is the caller's reply to that
+  // value. x is nil if the caller called Next instead of Send.
+  EmitPtrRecv() (ptr interface{}, x interface{})
+}
+
 // NewGenerator creates a new Generator that emits the values from emitting
 // function f. When f is through emitting values, it should just return. If
 // f gets nil when calling EmitPtr on e it should return immediately as this
-// means the Generator was closed.
+// means the Generator was closed. The returned Generator allows concurrent
+// Close as described for Generator; f finds out about such a Close the
+// next time it calls EmitPtr. While the caller reads from a sub-stream
+// that f passed to EmitAll, a concurrent Close stops the Next call in
+// progress only if the sub-stream allows concurrent Close too.
 func NewGenerator(f func(e Emitter)) Generator {
-  g := &regularGenerator{make(chan interface{}), make(chan bool)}
-  go genFuncWrapper(f, g)
-  g.cleanupIfDone()
-  return g
+  return newRegularGenerator(func(g *regularGenerator) { f(g) })
+}
+
+// NewSendGenerator works like NewGenerator except that the returned
+// Generator is a SendGenerator whose sent values f receives by calling
+// EmitPtrRecv on e.
+func NewSendGenerator(f func(e SendEmitter)) SendGenerator {
+  return newRegularGenerator(func(g *regularGenerator) { f(g) })
+}
+
+// EmitAll emits each value of s from the emitting function that e belongs
+// to, as if that function called EmitPtr and stored the next value of s at
+// the result until s is exhausted. Like EmitPtr, EmitAll first emits the
+// value stored at the previous result of EmitPtr. EmitAll returns the
+// pointer for the next value the emitting function emits or nil if the
+// client closed the associated Generator, in which case the emitting
+// function should simply return. EmitAll does not close s.
+//
+// When e comes from NewGenerator or NewSendGenerator, the client's calls to
+// Next read directly from s rather than going through the emitting
+// function, so s must be safe to use from the client's goroutine. Values
+// sent to a SendGenerator while it reads from s are dropped.
+func EmitAll(e Emitter, s Stream) interface{} {
+  if g, ok := e.(*regularGenerator); ok {
+    return g.emitAll(s)
+  }
+  ptr := e.EmitPtr()
+  for ptr != nil && s.Next(ptr) {
+    ptr = e.EmitPtr()
+  }
+  return ptr
 }
 
 // StreamToGenerator converts a Stream to a Generator. Closing the returned
@@ -41,38 +108,175 @@ func StreamToGenerator(s Stream, c io.Closer) Generator {
   return &simpleGenerator{s, c}
 }
 
+// States the emitting function of a regularGenerator reports.
+const (
+  kEmitted = iota
+  kDelegated
+  kDone
+)
+
 type regularGenerator struct {
+  // mu serializes calls to Send.
+  mu sync.Mutex
   ptrCh chan interface{}
-  doneCh chan bool
+  stateCh chan int
+  // closed is closed when the caller closes this Generator.
+  closed chan struct{}
+  closeOnce sync.Once
+  // delegate is the Stream from EmitAll that the caller reads directly.
+  delegate Stream
+  // sent is the value the caller sent along with the last ptr.
+  sent interface{}
+  // leak is non-nil while leak detection tracks this Generator.
+  leak *leakRecord
 }
 
-func (g *regularGenerator) Next(ptr interface{}) bool {
-  if g.ptrCh == nil {
-    return false
+func newRegularGenerator(f func(g *regularGenerator)) SendGenerator {
+  g := &regularGenerator{
+      ptrCh: make(chan interface{}),
+      stateCh: make(chan int),
+      closed: make(chan struct{})}
+  go genFuncWrapper(f, g)
+  if !g.waitForEmitter(nil) || !leakDetection.Load() {
+    return g
   }
-  g.ptrCh <- ptr
-  return g.cleanupIfDone()
+  return track(g)
+}
+
+func (g *regularGenerator) Next(ptr interface{}) bool {
+  return g.Send(ptr, nil)
+}
+
+func (g *regularGenerator) Send(ptr interface{}, x interface{}) bool {
+  g.mu.Lock()
+  defer g.mu.Unlock()
+  return g.send(ptr, x)
 }
 
+// Close tells the emitting function to stop and waits for it to return.
+// If a Send or Next call is in progress, Close instead makes that call
+// return false and returns without waiting; the emitting function then
+// returns the next time it calls EmitPtr.
 func (g *regularGenerator) Close() error {
-  g.Next(nil)
+  if g.mu.TryLock() {
+    g.send(nil, nil)
+    g.closeOnce.Do(func() { close(g.closed) })
+    g.mu.Unlock()
+    return nil
+  }
+  g.closeOnce.Do(func() { close(g.closed) })
+  untrack(g)
   return nil
 }
 
 func (g *regularGenerator) EmitPtr() interface{} {
-  g.doneCh <- false
-  return <-g.ptrCh
+  if !g.report(kEmitted) {
+    return nil
+  }
+  return g.receivePtr()
+}
+
+func (g *regularGenerator) EmitPtrRecv() (ptr interface{}, x interface{}) {
+  ptr = g.EmitPtr()
+  return ptr, g.sent
+}
+
+// emitAll hands s to the caller and waits until the caller exhausts s or
+// closes g.
+func (g *regularGenerator) emitAll(s Stream) interface{} {
+  ptr := g.EmitPtr()
+  if ptr == nil {
+    return nil
+  }
+  g.delegate = s
+  if !g.report(kDelegated) {
+    return nil
+  }
+  return g.receivePtr()
 }
 
-func (g *regularGenerator) cleanupIfDone() bool {
-  if <-g.doneCh {
-    close(g.ptrCh)
-    close(g.doneCh)
-    g.ptrCh = nil
-    g.doneCh = nil
+// report reports state to the caller. report returns false if the caller
+// closed g instead of waiting for state.
+func (g *regularGenerator) report(state int) bool {
+  select {
+  case g.stateCh <- state:
+    return true
+  case <-g.closed:
     return false
   }
-  return true
+}
+
+// receivePtr returns the next ptr from the caller or nil if the caller
+// closed g.
+func (g *regularGenerator) receivePtr() interface{} {
+  select {
+  case ptr := <-g.ptrCh:
+    return ptr
+  case <-g.closed:
+    return nil
+  }
+}
+
+// send works like Send. The caller must hold mu.
+func (g *regularGenerator) send(ptr interface{}, x interface{}) bool {
+  if g.delegate != nil {
+    if ptr != nil && g.delegate.Next(ptr) {
+      return true
+    }
+    g.delegate = nil
+  }
+  if g.ptrCh == nil {
+    return false
+  }
+  g.sent = x
+  if !g.sendPtr(ptr) {
+    return false
+  }
+  return g.waitForEmitter(ptr)
+}
+
+// sendPtr sends ptr to the emitting function. sendPtr returns false if g
+// was closed while sending.
+func (g *regularGenerator) sendPtr(ptr interface{}) bool {
+  select {
+  case g.ptrCh <- ptr:
+    return true
+  case <-g.closed:
+    return false
+  }
+}
+
+// waitForEmitter waits for the emitting function to store a value at ptr,
+// to delegate to a Stream, or to finish. waitForEmitter returns false if
+// the emitting function finished or g was closed while waiting.
+func (g *regularGenerator) waitForEmitter(ptr interface{}) bool {
+  for {
+    var state int
+    select {
+    case state = <-g.stateCh:
+    case <-g.closed:
+      return false
+    }
+    switch state {
+    case kEmitted:
+      return true
+    case kDelegated:
+      if g.delegate.Next(ptr) {
+        return true
+      }
+      g.delegate = nil
+      if !g.sendPtr(ptr) {
+        return false
+      }
+    case kDone:
+      close(g.ptrCh)
+      close(g.stateCh)
+      g.ptrCh = nil
+      g.stateCh = nil
+      untrack(g)
+      return false
+    }
+  }
 }
 
 type simpleGenerator struct {
@@ -80,7 +284,50 @@ type simpleGenerator struct {
   io.Closer
 }
 
-func genFuncWrapper(f func(e Emitter), g *regularGenerator) {
+func genFuncWrapper(f func(g *regularGenerator), g *regularGenerator) {
   f(g)
-  g.doneCh <- true
+  g.report(kDone)
+}
+
+// streamCloser closes each of its Streams that is an io.Closer exactly
+// once no matter how many times its Close method is called.
+type streamCloser struct {
+  streams []Stream
+  closed bool
+  err error
+}
+
+func newStreamCloser(s ...Stream) *streamCloser {
+  return &streamCloser{streams: s}
+}
+
+func (c *streamCloser) Close() error {
+  if c.closed {
+    return c.err
+  }
+  c.closed = true
+  var errs []error
+  for i, s := range c.streams {
+    closer, ok := s.(io.Closer)
+    if !ok || containsStream(c.streams[:i], s) {
+      continue
+    }
+    if err := closer.Close(); err != nil {
+      errs = append(errs, err)
+    }
+  }
+  c.err = errors.Join(errs...)
+  return c.err
+}
+
+func containsStream(streams []Stream, s Stream) bool {
+  if !reflect.TypeOf(s).Comparable() {
+    return false
+  }
+  for _, other := range streams {
+    if reflect.TypeOf(other) == reflect.TypeOf(s) && other == s {
+      return true
+    }
+  }
+  return false
 }
@@ -81,3 +81,207 @@ func TestEmptyGenerator(t *testing.T) {
   }
   g.Close()
 }
+
+func TestSendGenerator(t *testing.T) {
+  // Emits runs of consecutive ints. The caller sends the length of the
+  // next run.
+  g := NewSendGenerator(func(e SendEmitter) {
+    runLength := 1
+    for i := 0; ; i++ {
+      for j := 0; j < runLength; j++ {
+        ptr, x := e.EmitPtrRecv()
+        if ptr == nil {
+          return
+        }
+        if x != nil {
+          runLength = x.(int)
+        }
+        *ptr.(*int) = i
+      }
+    }
+  })
+  var x int
+  var results []int
+  g.Next(&x)
+  results = append(results, x)
+  g.Send(&x, 3)
+  results = append(results, x)
+  for i := 0; i < 4; i++ {
+    g.Next(&x)
+    results = append(results, x)
+  }
+  if output := fmt.Sprintf("%v", results); output != "[0 1 1 1 2 2]" {
+    t.Errorf("Expected [0 1 1 1 2 2] got %v", output)
+  }
+  g.Close()
+  if g.Send(&x, 1) {
+    t.Error("Expected closed Generator to be empty.")
+  }
+}
+
+func TestSendGeneratorReplies(t *testing.T) {
+  // Emits values and records the caller's reply to each one.
+  var replies []interface{}
+  g := NewSendGenerator(func(e SendEmitter) {
+    ptr := e.EmitPtr()
+    for i := 0; i < 3 && ptr != nil; i++ {
+      *ptr.(*int) = i
+      var reply interface{}
+      ptr, reply = e.EmitPtrRecv()
+      replies = append(replies, reply)
+    }
+  })
+  var x int
+  for g.Send(&x, fmt.Sprintf("saw %d", x)) {
+  }
+  if output := fmt.Sprintf("%v", replies); output != "[saw 0 saw 1 saw 2]" {
+    t.Errorf("Expected [saw 0 saw 1 saw 2] got %v", output)
+  }
+}
+
+func TestEmitAll(t *testing.T) {
+  g := NewGenerator(func(e Emitter) {
+    ptr := e.EmitPtr()
+    *ptr.(*int) = -1
+    ptr = EmitAll(e, xrange(0, 3))
+    if ptr == nil {
+      return
+    }
+    *ptr.(*int) = -2
+    ptr = EmitAll(e, NilStream())
+    if ptr == nil {
+      return
+    }
+    *ptr.(*int) = -3
+    e.EmitPtr()
+  })
+  var results []int
+  AppendValues(g, &results)
+  if output := fmt.Sprintf("%v", results); output != "[-1 0 1 2 -2 -3]" {
+    t.Errorf("Expected [-1 0 1 2 -2 -3] got %v", output)
+  }
+}
+
+func TestEmitAllRecursive(t *testing.T) {
+  var results []int
+  AppendValues(inOrder(newTree(1, 15)), &results)
+  if output := fmt.Sprintf("%v", results); output != "[1 2 3 4 5 6 7 8 9 10 11 12 13 14 15]" {
+    t.Errorf("Expected 1 through 15 got %v", output)
+  }
+}
+
+func TestEmitAllClose(t *testing.T) {
+  sub := &closeCountingGenerator{Stream: Count()}
+  var closed bool
+  g := NewGenerator(func(e Emitter) {
+    EmitAll(e, sub)
+    closed = true
+  })
+  var results []int
+  AppendValues(Slice(g, 0, 3), &results)
+  if output := fmt.Sprintf("%v", results); output != "[0 1 2]" {
+    t.Errorf("Expected [0 1 2] got %v", output)
+  }
+  g.Close()
+  if !closed {
+    t.Error("Expected EmitAll to return on close.")
+  }
+  if sub.closed != 0 {
+    t.Error("Expected EmitAll to leave sub-stream open.")
+  }
+  if g.Next(new(int)) {
+    t.Error("Expected closed Generator to be empty.")
+  }
+}
+
+func TestEmitAllOtherEmitter(t *testing.T) {
+  e := &sliceEmitter{}
+  ptr := EmitAll(e, xrange(0, 3))
+  *ptr.(*int) = 3
+  e.EmitPtr()
+  if output := fmt.Sprintf("%v", e.values); output != "[0 1 2 3]" {
+    t.Errorf("Expected [0 1 2 3] got %v", output)
+  }
+}
+
+type tree struct {
+  value int
+  left, right *tree
+}
+
+// newTree returns a balanced tree holding start through end inclusive.
+func newTree(start, end int) *tree {
+  if start > end {
+    return nil
+  }
+  mid := (start + end) / 2
+  return &tree{mid, newTree(start, mid - 1), newTree(mid + 1, end)}
+}
+
+func inOrder(t *tree) Generator {
+  return NewGenerator(func(e Emitter) {
+    if t == nil {
+      return
+    }
+    left := inOrder(t.left)
+    defer left.Close()
+    ptr := EmitAll(e, left)
+    if ptr == nil {
+      return
+    }
+    *ptr.(*int) = t.value
+    right := inOrder(t.right)
+    defer right.Close()
+    EmitAll(e, right)
+  })
+}
+
+// sliceEmitter is an Emitter that appends what it emits to values.
+type sliceEmitter struct {
+  values []int
+  ptr *int
+}
+
+func (e *sliceEmitter) EmitPtr() interface{} {
+  if e.ptr != nil {
+    e.values = append(e.values, *e.ptr)
+  }
+  e.ptr = new(int)
+  return e.ptr
+}
+
+func TestNewGeneratorCloseWhileWaiting(t *testing.T) {
+  values := make(chan int)
+  asked := make(chan struct{}, 10)
+  g := chanEmitter(values, asked)
+  result := make(chan bool)
+  go func() {
+    result <- g.Next(new(int))
+  }()
+  // The emitting function waits for a value.
+  <-asked
+  g.Close()
+  if <-result {
+    t.Error("Expected Next to return false.")
+  }
+  close(values)
+  if g.Next(new(int)) {
+    t.Error("Expected closed Generator to be empty.")
+  }
+}
+
+// chanEmitter returns a Generator from NewGenerator that emits the values
+// it receives from values. The emitting function signals asked each time
+// it waits for a value.
+func chanEmitter(values chan int, asked chan struct{}) Generator {
+  return NewGenerator(func(e Emitter) {
+    for ptr := e.EmitPtr(); ptr != nil; ptr = e.EmitPtr() {
+      asked <- struct{}{}
+      x, ok := <-values
+      if !ok {
+        return
+      }
+      *ptr.(*int) = x
+    }
+  })
+}
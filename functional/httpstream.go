@@ -0,0 +1,352 @@
+package functional
+
+import (
+  "bufio"
+  "bytes"
+  "context"
+  "encoding/json"
+  "errors"
+  "io"
+  "math"
+  "mime"
+  "net/http"
+  "strings"
+  "sync"
+  "time"
+)
+
+const (
+  kNDJSONContentType = "application/x-ndjson"
+  kSSEContentType = "text/event-stream"
+  // kStreamErrorTrailer is the HTTP trailer that reports the error that
+  // stopped a Stream.
+  kStreamErrorTrailer = "X-Stream-Error"
+)
+
+// StreamFormat is the format StreamHandler uses to write values.
+type StreamFormat int
+
+const (
+  // AutoFormat chooses ServerSentEvents if the request accepts
+  // text/event-stream and NDJSON otherwise.
+  AutoFormat StreamFormat = iota
+  // NDJSON writes one JSON value per line.
+  NDJSON
+  // ServerSentEvents writes each JSON value as the data of a server-sent
+  // event.
+  ServerSentEvents
+)
+
+// StreamHandler is an http.Handler that writes the values of a Stream of T
+// as JSON. For each request, StreamHandler gets a new Stream from Factory
+// and writes its values as they are emitted. StreamHandler stops when the
+// Stream is exhausted or the client goes away, whichever comes first,
+// closing the Stream if it is an io.Closer. StreamHandler closes the
+// Stream as soon as the client goes away, even while the Stream waits for
+// a value, so a Stream that is an io.Closer must allow concurrent Close as
+// described for Generator. If the Stream stops because of an error,
+// StreamHandler reports the error in the X-Stream-Error HTTP trailer.
+type StreamHandler struct {
+  // Factory returns the Stream of T to serve for a request. If Factory
+  // returns an error, StreamHandler responds with an internal server error.
+  Factory func(r *http.Request) (Stream, error)
+  // Creater is a Creater of T.
+  Creater Creater
+  // Format is the format of the response.
+  Format StreamFormat
+  // FlushInterval is how often to flush written values to the client. If
+  // zero, each value is flushed as soon as it is written.
+  FlushInterval time.Duration
+  // Clock is used for timing flushes. If nil, SystemClock is used.
+  Clock Clock
+}
+
+func (h *StreamHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+  s, err := h.Factory(r)
+  if err != nil {
+    http.Error(w, err.Error(), http.StatusInternalServerError)
+    return
+  }
+  if c, ok := s.(io.Closer); ok {
+    defer closeOnDone(r.Context(), c)()
+  }
+  format := h.format(r)
+  header := w.Header()
+  if format == ServerSentEvents {
+    header.Set("Content-Type", kSSEContentType)
+  } else {
+    header.Set("Content-Type", kNDJSONContentType)
+  }
+  header.Set("Cache-Control", "no-cache")
+  header.Set("Trailer", kStreamErrorTrailer)
+  w.WriteHeader(http.StatusOK)
+  fw := &flushWriter{w: w}
+  fw.flusher, _ = w.(http.Flusher)
+  if h.FlushInterval > 0 {
+    stop := fw.flushPeriodically(h.FlushInterval, clockOrDefault(h.Clock))
+    defer stop()
+  }
+  err = writeJSONValues(r, fw, s, h.Creater(), format, h.FlushInterval <= 0)
+  if err == nil {
+    if es, ok := s.(ErrStream); ok {
+      err = es.Err()
+    }
+  }
+  fw.finish(err)
+}
+
+func (h *StreamHandler) format(r *http.Request) StreamFormat {
+  if h.Format != AutoFormat {
+    return h.Format
+  }
+  if strings.Contains(r.Header.Get("Accept"), kSSEContentType) {
+    return ServerSentEvents
+  }
+  return NDJSON
+}
+
+// ReadNDJSON returns the JSON values in r, one per line, as a Stream of T.
+// If r contains malformed JSON or reading r fails, the returned Stream
+// stops and its Err method reports the error.
+func ReadNDJSON(r io.Reader) ErrStream {
+  return &ndjsonStream{decoder: json.NewDecoder(r)}
+}
+
+// ReadSSE returns the JSON values in the data of the server-sent events in
+// r as a Stream of T. Events without data are ignored. If an event contains
+// malformed JSON or reading r fails, the returned Stream stops and its Err
+// method reports the error.
+func ReadSSE(r io.Reader) ErrStream {
+  return &sseStream{lines: ReadTokens(r, bufio.ScanLines, 0)}
+}
+
+// NewHTTPStream returns a Generator of T that emits the values in the body
+// of resp, a response from a StreamHandler. The format of the body is
+// determined from its Content-Type. If resp reports an error, or if the
+// body is malformed, the Generator's Err method, from ErrStream, reports
+// the error. Closing the Generator closes the body of resp.
+func NewHTTPStream(resp *http.Response) Generator {
+  g := &httpStream{resp: resp}
+  if resp.StatusCode != http.StatusOK {
+    g.err = errors.New(resp.Status)
+    return g
+  }
+  mediaType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+  if mediaType == kSSEContentType {
+    g.s = ReadSSE(resp.Body)
+  } else {
+    g.s = ReadNDJSON(resp.Body)
+  }
+  return g
+}
+
+func writeJSONValues(
+    r *http.Request,
+    fw *flushWriter,
+    s Stream,
+    ptr interface{},
+    format StreamFormat,
+    flushEach bool) error {
+  ctx := r.Context()
+  var b bytes.Buffer
+  encoder := json.NewEncoder(&b)
+  for ctx.Err() == nil && s.Next(ptr) {
+    b.Reset()
+    if format == ServerSentEvents {
+      b.WriteString("data: ")
+    }
+    if err := encoder.Encode(ptr); err != nil {
+      return err
+    }
+    if format == ServerSentEvents {
+      b.WriteString("\n")
+    }
+    if err := fw.write(b.Bytes(), flushEach); err != nil {
+      // The client went away.
+      return nil
+    }
+  }
+  return nil
+}
+
+// closeOnDone closes c as soon as ctx is done. Calling the returned
+// function closes c unless it is already closed.
+func closeOnDone(ctx context.Context, c io.Closer) (closeNow func()) {
+  var once sync.Once
+  done := make(chan struct{})
+  stopped := make(chan struct{})
+  go func() {
+    defer close(stopped)
+    select {
+    case <-ctx.Done():
+      once.Do(func() { c.Close() })
+    case <-done:
+    }
+  }()
+  return func() {
+    close(done)
+    <-stopped
+    once.Do(func() { c.Close() })
+  }
+}
+
+// flushWriter serializes writing and flushing a response so that flushes
+// can happen on a separate goroutine.
+type flushWriter struct {
+  mu sync.Mutex
+  w http.ResponseWriter
+  flusher http.Flusher
+}
+
+func (f *flushWriter) write(p []byte, flush bool) error {
+  f.mu.Lock()
+  defer f.mu.Unlock()
+  if _, err := f.w.Write(p); err != nil {
+    return err
+  }
+  if flush {
+    f.flush()
+  }
+  return nil
+}
+
+func (f *flushWriter) flush() {
+  if f.flusher != nil {
+    f.flusher.Flush()
+  }
+}
+
+// flushPeriodically flushes every interval until the returned function is
+// called.
+func (f *flushWriter) flushPeriodically(interval time.Duration, clock Clock) (stop func()) {
+  done := make(chan struct{})
+  stopped := make(chan struct{})
+  go func() {
+    defer close(stopped)
+    for {
+      select {
+      case <-done:
+        return
+      case <-clock.After(interval):
+        f.mu.Lock()
+        f.flush()
+        f.mu.Unlock()
+      }
+    }
+  }()
+  return func() {
+    close(done)
+    <-stopped
+  }
+}
+
+func (f *flushWriter) finish(err error) {
+  f.mu.Lock()
+  defer f.mu.Unlock()
+  if err != nil {
+    f.w.Header().Set(kStreamErrorTrailer, err.Error())
+  }
+  f.flush()
+}
+
+type ndjsonStream struct {
+  decoder *json.Decoder
+  err error
+}
+
+func (s *ndjsonStream) Next(ptr interface{}) bool {
+  if s.err != nil {
+    return false
+  }
+  if err := s.decoder.Decode(ptr); err != nil {
+    if err != io.EOF {
+      s.err = err
+    }
+    return false
+  }
+  return true
+}
+
+func (s *ndjsonStream) Err() error {
+  return s.err
+}
+
+type sseStream struct {
+  lines ErrStream
+  line string
+  data []byte
+  err error
+}
+
+func (s *sseStream) Next(ptr interface{}) bool {
+  if s.err != nil {
+    return false
+  }
+  s.data = s.data[:0]
+  hasData := false
+  for s.lines.Next(&s.line) {
+    if s.line == "" {
+      if !hasData {
+        continue
+      }
+      if err := json.Unmarshal(s.data, ptr); err != nil {
+        s.err = err
+        return false
+      }
+      return true
+    }
+    field, value, _ := strings.Cut(s.line, ":")
+    if field != "data" {
+      continue
+    }
+    if hasData {
+      s.data = append(s.data, '\n')
+    }
+    hasData = true
+    s.data = append(s.data, strings.TrimPrefix(value, " ")...)
+  }
+  s.err = s.lines.Err()
+  return false
+}
+
+func (s *sseStream) Err() error {
+  return s.err
+}
+
+type httpStream struct {
+  resp *http.Response
+  s ErrStream
+  err error
+  done bool
+  closed bool
+}
+
+func (g *httpStream) Next(ptr interface{}) bool {
+  if g.done || g.closed || g.s == nil {
+    return false
+  }
+  if g.s.Next(ptr) {
+    return true
+  }
+  g.done = true
+  if g.err = g.s.Err(); g.err == nil {
+    // Trailers are available once the body has been read.
+    io.Copy(io.Discard, io.LimitReader(g.resp.Body, math.MaxInt16))
+    if message := g.resp.Trailer.Get(kStreamErrorTrailer); message != "" {
+      g.err = errors.New(message)
+    }
+  }
+  return false
+}
+
+func (g *httpStream) Err() error {
+  return g.err
+}
+
+func (g *httpStream) Close() error {
+  if g.closed {
+    return nil
+  }
+  g.closed = true
+  return g.resp.Body.Close()
+}
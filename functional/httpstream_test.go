@@ -0,0 +1,282 @@
+package functional
+
+import (
+    "context"
+    "errors"
+    "fmt"
+    "net/http"
+    "net/http/httptest"
+    "strings"
+    "testing"
+    "time"
+)
+
+func TestStreamHandlerNDJSON(t *testing.T) {
+  server := httptest.NewServer(&StreamHandler{
+      Factory: func(r *http.Request) (Stream, error) {
+        return NewStreamFromValues(records(50)), nil
+      },
+      Creater: func() interface{} { return new(record) }})
+  defer server.Close()
+  resp, err := http.Get(server.URL)
+  if err != nil {
+    t.Fatalf("Got error %v", err)
+  }
+  if ct := resp.Header.Get("Content-Type"); ct != "application/x-ndjson" {
+    t.Errorf("Expected application/x-ndjson got %v", ct)
+  }
+  g := NewHTTPStream(resp)
+  defer g.Close()
+  var results []record
+  AppendValues(g, &results)
+  if len(results) != 50 || results[49].Name != "r49" {
+    t.Errorf("Expected 50 records got %v", len(results))
+  }
+  if err := g.(ErrStream).Err(); err != nil {
+    t.Errorf("Expected no error got %v", err)
+  }
+}
+
+func TestStreamHandlerSSE(t *testing.T) {
+  server := httptest.NewServer(&StreamHandler{
+      Factory: func(r *http.Request) (Stream, error) {
+        return xrange(0, 5), nil
+      },
+      Creater: func() interface{} { return new(int) }})
+  defer server.Close()
+  req, _ := http.NewRequest("GET", server.URL, nil)
+  req.Header.Set("Accept", "text/event-stream")
+  resp, err := http.DefaultClient.Do(req)
+  if err != nil {
+    t.Fatalf("Got error %v", err)
+  }
+  if ct := resp.Header.Get("Content-Type"); ct != "text/event-stream" {
+    t.Errorf("Expected text/event-stream got %v", ct)
+  }
+  g := NewHTTPStream(resp)
+  defer g.Close()
+  var results []int
+  AppendValues(g, &results)
+  if output := fmt.Sprintf("%v", results); output != "[0 1 2 3 4]" {
+    t.Errorf("Expected [0 1 2 3 4] got %v", output)
+  }
+}
+
+func TestStreamHandlerStreamError(t *testing.T) {
+  server := httptest.NewServer(&StreamHandler{
+      Factory: func(r *http.Request) (Stream, error) {
+        return &errAfterStream{n: 2, err: errors.New("bad disk")}, nil
+      },
+      Creater: func() interface{} { return new(int) }})
+  defer server.Close()
+  resp, err := http.Get(server.URL)
+  if err != nil {
+    t.Fatalf("Got error %v", err)
+  }
+  g := NewHTTPStream(resp)
+  defer g.Close()
+  var results []int
+  AppendValues(g, &results)
+  if output := fmt.Sprintf("%v", results); output != "[0 1]" {
+    t.Errorf("Expected [0 1] got %v", output)
+  }
+  if err := g.(ErrStream).Err(); err == nil || err.Error() != "bad disk" {
+    t.Errorf("Expected 'bad disk' got %v", err)
+  }
+}
+
+func TestStreamHandlerFactoryError(t *testing.T) {
+  server := httptest.NewServer(&StreamHandler{
+      Factory: func(r *http.Request) (Stream, error) {
+        return nil, errors.New("no such query")
+      },
+      Creater: func() interface{} { return new(int) }})
+  defer server.Close()
+  resp, err := http.Get(server.URL)
+  if err != nil {
+    t.Fatalf("Got error %v", err)
+  }
+  g := NewHTTPStream(resp)
+  defer g.Close()
+  if g.Next(new(int)) {
+    t.Error("Expected empty Generator.")
+  }
+  if g.(ErrStream).Err() == nil {
+    t.Error("Expected an error.")
+  }
+}
+
+func TestStreamHandlerClientGoesAway(t *testing.T) {
+  source := &countingGenerator{}
+  served := make(chan struct{})
+  handler := &StreamHandler{
+      Factory: func(r *http.Request) (Stream, error) {
+        return source, nil
+      },
+      Creater: func() interface{} { return new(int) }}
+  server := httptest.NewServer(http.HandlerFunc(
+      func(w http.ResponseWriter, r *http.Request) {
+        defer close(served)
+        handler.ServeHTTP(w, r)
+      }))
+  defer server.Close()
+  resp, err := http.Get(server.URL)
+  if err != nil {
+    t.Fatalf("Got error %v", err)
+  }
+  g := NewHTTPStream(resp)
+  var results []int
+  AppendValues(Slice(g, 0, 3), &results)
+  if output := fmt.Sprintf("%v", results); output != "[0 1 2]" {
+    t.Errorf("Expected [0 1 2] got %v", output)
+  }
+  g.Close()
+  <-served
+  if !source.isClosed() {
+    t.Error("Expected handler to close its Stream.")
+  }
+  if g.Next(new(int)) {
+    t.Error("Expected closed Generator to be empty.")
+  }
+}
+
+func TestStreamHandlerClientGoesAwayWhileWaiting(t *testing.T) {
+  name := writeTempFile(t, []byte("a\n"))
+  clock := newFakeClock()
+  source, err := FollowLines(name, &FollowOptions{Interval: time.Second, Clock: clock})
+  if err != nil {
+    t.Fatalf("Got error %v", err)
+  }
+  handler := &StreamHandler{
+      Factory: func(r *http.Request) (Stream, error) {
+        return source, nil
+      },
+      Creater: func() interface{} { return new(string) }}
+  ctx, cancel := context.WithCancel(context.Background())
+  w := httptest.NewRecorder()
+  done := make(chan struct{})
+  go func() {
+    handler.ServeHTTP(w, httptest.NewRequest("GET", "/", nil).WithContext(ctx))
+    close(done)
+  }()
+  // The source waits for more lines after emitting "a".
+  clock.WaitForWaiters(1)
+  cancel()
+  <-done
+  if output := w.Body.String(); output != "\"a\"\n" {
+    t.Errorf("Expected %q got %q", "\"a\"\n", output)
+  }
+  if source.Next(new(string)) {
+    t.Error("Expected handler to close its Stream.")
+  }
+}
+
+func TestStreamHandlerFlushInterval(t *testing.T) {
+  clock := newFakeClock()
+  values := chanStream{values: make(chan int), asked: make(chan struct{})}
+  handler := &StreamHandler{
+      Factory: func(r *http.Request) (Stream, error) {
+        return values, nil
+      },
+      Creater: func() interface{} { return new(int) },
+      FlushInterval: time.Second,
+      Clock: clock}
+  w := &flushRecorder{ResponseRecorder: httptest.NewRecorder(), flushed: make(chan string, 10)}
+  done := make(chan struct{})
+  go func() {
+    handler.ServeHTTP(w, httptest.NewRequest("GET", "/", nil))
+    close(done)
+  }()
+  for _, x := range []int{3, 4} {
+    <-values.asked
+    values.values <- x
+  }
+  // The handler asks for the next value only after writing 4.
+  <-values.asked
+  clock.WaitForWaiters(1)
+  clock.Advance(time.Second)
+  if output := <-w.flushed; output != "3\n4\n" {
+    t.Errorf("Expected '3\\n4\\n' got %q", output)
+  }
+  close(values.values)
+  <-done
+  if output := w.Body.String(); output != "3\n4\n" {
+    t.Errorf("Expected '3\\n4\\n' got %q", output)
+  }
+}
+
+func TestReadSSE(t *testing.T) {
+  r := strings.NewReader(
+      ": comment\n\nevent: x\ndata: 1\n\ndata: [2,\ndata: 3]\n\nid: 7\n\ndata:4\n")
+  s := ReadSSE(r)
+  var results []interface{}
+  var x interface{}
+  for s.Next(&x) {
+    results = append(results, x)
+  }
+  if output := fmt.Sprintf("%v", results); output != "[1 [2 3]]" {
+    t.Errorf("Expected [1 [2 3]] got %v", output)
+  }
+  if err := s.Err(); err != nil {
+    t.Errorf("Expected no error got %v", err)
+  }
+}
+
+func TestReadNDJSONMalformed(t *testing.T) {
+  s := ReadNDJSON(strings.NewReader("1\n2\nthree\n4\n"))
+  var results []int
+  AppendValues(s, &results)
+  if output := fmt.Sprintf("%v", results); output != "[1 2]" {
+    t.Errorf("Expected [1 2] got %v", output)
+  }
+  if s.Err() == nil {
+    t.Error("Expected an error.")
+  }
+}
+
+// errAfterStream emits 0 through n-1 and then stops with err.
+type errAfterStream struct {
+  i int
+  n int
+  err error
+}
+
+func (s *errAfterStream) Next(ptr interface{}) bool {
+  if s.i == s.n {
+    return false
+  }
+  *ptr.(*int) = s.i
+  s.i++
+  return true
+}
+
+func (s *errAfterStream) Err() error {
+  return s.err
+}
+
+// chanStream is a Stream of int that emits the values sent on values.
+// Each call to Next first signals on asked.
+type chanStream struct {
+  values chan int
+  asked chan struct{}
+}
+
+func (s chanStream) Next(ptr interface{}) bool {
+  s.asked <- struct{}{}
+  x, ok := <-s.values
+  if ok {
+    *ptr.(*int) = x
+  }
+  return ok
+}
+
+// flushRecorder reports what has been written each time it is flushed.
+type flushRecorder struct {
+  *httptest.ResponseRecorder
+  flushed chan string
+}
+
+func (f *flushRecorder) Flush() {
+  f.ResponseRecorder.Flush()
+  f.flushed <- f.Body.String()
+}
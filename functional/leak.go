@@ -0,0 +1,156 @@
+package functional
+
+import (
+  "fmt"
+  "log"
+  "runtime"
+  "runtime/debug"
+  "sort"
+  "sync"
+  "sync/atomic"
+  "time"
+)
+
+// GeneratorInfo describes a Generator from NewGenerator that is neither
+// exhausted nor closed.
+type GeneratorInfo struct {
+  // Created is when the Generator was created.
+  Created time.Time
+  // Stack is the stack trace of the goroutine that created the Generator.
+  Stack string
+}
+
+func (g GeneratorInfo) String() string {
+  return fmt.Sprintf("Generator created at %v by:\n%s", g.Created, g.Stack)
+}
+
+// TB is the part of testing.TB that CheckGeneratorLeaks uses.
+type TB interface {
+  Helper()
+  Errorf(format string, args ...interface{})
+}
+
+var (
+  leakDetection atomic.Bool
+  liveMu sync.Mutex
+  // liveGenerators and warnLeak are guarded by liveMu.
+  liveGenerators = make(map[*leakRecord]bool)
+  // warnLeak is called when a Generator that is neither exhausted nor
+  // closed is garbage collected.
+  warnLeak = func(info GeneratorInfo) {
+    log.Printf("functional: unclosed %v", info)
+  }
+)
+
+func init() {
+  leakDetection.Store(kLeakDetectionDefault)
+}
+
+// SetLeakDetection turns leak detection on or off. When leak detection is
+// on, each Generator that NewGenerator creates is tracked until it is
+// exhausted or closed. If such a Generator is garbage collected before then,
+// a warning with its creation stack trace is logged and the Generator is
+// closed so that its goroutine exits. Leak detection slows down
+// NewGenerator, so it is off by default unless this package is built with
+// the functional_leakcheck build tag. Turning leak detection on affects
+// only Generators created afterwards.
+func SetLeakDetection(on bool) {
+  leakDetection.Store(on)
+}
+
+// LiveGenerators returns the tracked Generators that are neither exhausted
+// nor closed, oldest first. LiveGenerators returns nothing if leak
+// detection has never been on.
+func LiveGenerators() []GeneratorInfo {
+  records := liveRecords()
+  result := make([]GeneratorInfo, len(records))
+  for i := range records {
+    result[i] = records[i].info
+  }
+  return result
+}
+
+// CheckGeneratorLeaks turns on leak detection and returns a function that
+// fails t if any Generator created in between is neither exhausted nor
+// closed. The returned function also restores leak detection to what it
+// was. Typical usage:
+//
+//   defer functional.CheckGeneratorLeaks(t)()
+func CheckGeneratorLeaks(t TB) func() {
+  t.Helper()
+  wasOn := leakDetection.Swap(true)
+  before := make(map[*leakRecord]bool)
+  for _, record := range liveRecords() {
+    before[record] = true
+  }
+  return func() {
+    t.Helper()
+    leakDetection.Store(wasOn)
+    for _, record := range liveRecords() {
+      if !before[record] {
+        t.Errorf("Leaked %v", record.info)
+      }
+    }
+  }
+}
+
+type leakRecord struct {
+  info GeneratorInfo
+}
+
+// trackedGenerator is what NewGenerator returns when leak detection is on.
+// The goroutine emitting values refers only to the regularGenerator so that
+// a trackedGenerator the caller drops can be garbage collected.
+type trackedGenerator struct {
+  *regularGenerator
+}
+
+func track(g *regularGenerator) SendGenerator {
+  record := &leakRecord{GeneratorInfo{Created: time.Now(), Stack: string(debug.Stack())}}
+  liveMu.Lock()
+  liveGenerators[record] = true
+  g.leak = record
+  liveMu.Unlock()
+  result := &trackedGenerator{g}
+  runtime.SetFinalizer(result, finalizeGenerator)
+  return result
+}
+
+// untrack stops tracking g because it is exhausted or closed.
+func untrack(g *regularGenerator) {
+  liveMu.Lock()
+  defer liveMu.Unlock()
+  if g.leak != nil {
+    delete(liveGenerators, g.leak)
+    g.leak = nil
+  }
+}
+
+func finalizeGenerator(t *trackedGenerator) {
+  liveMu.Lock()
+  record := t.leak
+  warn := warnLeak
+  if record != nil {
+    delete(liveGenerators, record)
+    t.leak = nil
+  }
+  liveMu.Unlock()
+  if record == nil {
+    return
+  }
+  warn(record.info)
+  t.Close()
+}
+
+func liveRecords() []*leakRecord {
+  liveMu.Lock()
+  result := make([]*leakRecord, 0, len(liveGenerators))
+  for record := range liveGenerators {
+    result = append(result, record)
+  }
+  liveMu.Unlock()
+  sort.Slice(result, func(i, j int) bool {
+    return result[i].info.Created.Before(result[j].info.Created)
+  })
+  return result
+}
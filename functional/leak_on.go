@@ -0,0 +1,5 @@
+//go:build functional_leakcheck
+
+package functional
+
+const kLeakDetectionDefault = true
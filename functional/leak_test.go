@@ -0,0 +1,142 @@
+package functional
+
+import (
+    "fmt"
+    "runtime"
+    "strings"
+    "testing"
+    "time"
+)
+
+func TestLiveGenerators(t *testing.T) {
+  defer SetLeakDetection(leakDetection.Load())
+  SetLeakDetection(true)
+  before := len(LiveGenerators())
+  g := countingEmitter()
+  closed := Slice(countingEmitter(), 0, 3)
+  AppendValues(closed, new([]int))
+  closed.Close()
+  live := LiveGenerators()
+  if len(live) != before + 1 {
+    t.Fatalf("Expected %v live generators got %v", before + 1, len(live))
+  }
+  if stack := live[len(live) - 1].Stack; !strings.Contains(stack, "TestLiveGenerators") {
+    t.Errorf("Expected creation stack got %v", stack)
+  }
+  g.Close()
+  if n := len(LiveGenerators()); n != before {
+    t.Errorf("Expected %v live generators got %v", before, n)
+  }
+}
+
+func TestLeakDetectionOff(t *testing.T) {
+  defer SetLeakDetection(leakDetection.Load())
+  SetLeakDetection(false)
+  before := len(LiveGenerators())
+  g := countingEmitter()
+  defer g.Close()
+  if n := len(LiveGenerators()); n != before {
+    t.Errorf("Expected %v live generators got %v", before, n)
+  }
+  if _, ok := g.(*regularGenerator); !ok {
+    t.Error("Expected untracked Generator.")
+  }
+}
+
+func TestExhaustedGeneratorNotTracked(t *testing.T) {
+  defer CheckGeneratorLeaks(t)()
+  var results []int
+  AppendValues(Slice(NewGenerator(func(e Emitter) {}), 0, 5), &results)
+  AppendValues(NewGenerator(func(e Emitter) {
+    for i := 0; i < 3; i++ {
+      *e.EmitPtr().(*int) = i
+    }
+    e.EmitPtr()
+  }), &results)
+  if output := fmt.Sprintf("%v", results); output != "[0 1 2]" {
+    t.Errorf("Expected [0 1 2] got %v", output)
+  }
+}
+
+func TestCheckGeneratorLeaks(t *testing.T) {
+  fake := &fakeTB{}
+  check := CheckGeneratorLeaks(fake)
+  leaked := countingEmitter()
+  closed := countingEmitter()
+  closed.Close()
+  check()
+  if len(fake.errors) != 1 || !strings.Contains(fake.errors[0], "TestCheckGeneratorLeaks") {
+    t.Errorf("Expected one leak reported got %v", fake.errors)
+  }
+  leaked.Close()
+}
+
+func TestLeakFinalizer(t *testing.T) {
+  defer SetLeakDetection(leakDetection.Load())
+  SetLeakDetection(true)
+  warnings := make(chan GeneratorInfo, 1)
+  setWarnLeak := func(f func(info GeneratorInfo)) func(info GeneratorInfo) {
+    liveMu.Lock()
+    defer liveMu.Unlock()
+    result := warnLeak
+    warnLeak = f
+    return result
+  }
+  oldWarnLeak := setWarnLeak(func(info GeneratorInfo) {
+    if strings.Contains(info.Stack, "dropPartiallyConsumedGenerator") {
+      select {
+      case warnings <- info:
+      default:
+      }
+    }
+  })
+  defer setWarnLeak(oldWarnLeak)
+  dropPartiallyConsumedGenerator()
+  timeout := time.After(10 * time.Second)
+  for {
+    runtime.GC()
+    select {
+    case info := <-warnings:
+      for _, live := range LiveGenerators() {
+        if live.Stack == info.Stack && live.Created.Equal(info.Created) {
+          t.Error("Expected the dropped Generator to no longer be live.")
+        }
+      }
+      return
+    case <-timeout:
+      t.Fatal("Expected a warning for the dropped Generator.")
+    case <-time.After(10 * time.Millisecond):
+    }
+  }
+}
+
+func dropPartiallyConsumedGenerator() {
+  g := countingEmitter()
+  var x int
+  g.Next(&x)
+}
+
+// countingEmitter returns an infinite Generator from NewGenerator that
+// emits 0, 1, 2, ...
+func countingEmitter() Generator {
+  return NewGenerator(func(e Emitter) {
+    for i := 0; ; i++ {
+      ptr := e.EmitPtr()
+      if ptr == nil {
+        return
+      }
+      *ptr.(*int) = i
+    }
+  })
+}
+
+type fakeTB struct {
+  errors []string
+}
+
+func (f *fakeTB) Helper() {
+}
+
+func (f *fakeTB) Errorf(format string, args ...interface{}) {
+  f.errors = append(f.errors, fmt.Sprintf(format, args...))
+}
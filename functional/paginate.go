@@ -0,0 +1,121 @@
+package functional
+
+import (
+  "sync"
+)
+
+// PaginateOptions are the options for Paginate.
+type PaginateOptions struct {
+  // Prefetch, if true, fetches the next page on a separate goroutine while
+  // the values of the current page are being emitted.
+  Prefetch bool
+  // Retry says how to retry failed fetches. If nil, failed fetches are
+  // not retried.
+  Retry *RetryPolicy
+}
+
+// Paginate returns a Generator of T that emits the values of a paginated
+// source. fetch returns the page for a continuation token as a []T along
+// with the token of the next page; an empty next token means there are no
+// more pages. The first page is fetched with the empty token. Pages are
+// fetched only when the values of the previous page have all been emitted,
+// so a caller that stops calling Next stops the fetching. If a fetch fails
+// even after retries, Next returns false and the Generator's Err method,
+// from ErrStream, reports the error. Closing the Generator abandons any
+// prefetch or retry in progress. opts may be nil.
+func Paginate(
+    fetch func(token string) (items interface{}, next string, err error),
+    opts *PaginateOptions) Generator {
+  if opts == nil {
+    opts = &PaginateOptions{}
+  }
+  return &paginator{
+      fetch: fetch,
+      prefetch: opts.Prefetch,
+      retry: opts.Retry,
+      page: NewStreamFromValues([]struct{}{}),
+      more: true,
+      done: make(chan struct{})}
+}
+
+type page struct {
+  items interface{}
+  next string
+  err error
+}
+
+type paginator struct {
+  fetch func(token string) (items interface{}, next string, err error)
+  prefetch bool
+  retry *RetryPolicy
+  page Stream
+  next string
+  // more is true if there are pages after the current one.
+  more bool
+  // pending receives the page being prefetched.
+  pending chan page
+  done chan struct{}
+  closeOnce sync.Once
+  err error
+}
+
+func (p *paginator) Next(ptr interface{}) bool {
+  if p.isClosed() {
+    return false
+  }
+  for !p.page.Next(ptr) {
+    if !p.more || p.err != nil {
+      return false
+    }
+    var result page
+    if p.pending != nil {
+      result = <-p.pending
+      p.pending = nil
+    } else {
+      result = p.fetchPage(p.next)
+    }
+    if result.err != nil {
+      p.err = result.err
+      return false
+    }
+    p.next = result.next
+    p.more = result.next != ""
+    p.page = NewStreamFromValues([]struct{}{})
+    if result.items != nil {
+      p.page = NewStreamFromValues(result.items)
+    }
+    if p.prefetch && p.more {
+      p.pending = make(chan page, 1)
+      go func(token string, pending chan<- page) {
+        pending <- p.fetchPage(token)
+      }(p.next, p.pending)
+    }
+  }
+  return true
+}
+
+func (p *paginator) Err() error {
+  return p.err
+}
+
+func (p *paginator) Close() error {
+  p.closeOnce.Do(func() { close(p.done) })
+  return nil
+}
+
+func (p *paginator) fetchPage(token string) (result page) {
+  result.err = p.retry.do(p.done, func() (err error) {
+    result.items, result.next, err = p.fetch(token)
+    return
+  })
+  return
+}
+
+func (p *paginator) isClosed() bool {
+  select {
+  case <-p.done:
+    return true
+  default:
+    return false
+  }
+}
@@ -0,0 +1,161 @@
+package functional
+
+import (
+    "errors"
+    "fmt"
+    "strconv"
+    "sync"
+    "testing"
+    "time"
+)
+
+func TestPaginate(t *testing.T) {
+  api := &pagedAPI{pages: 4, pageSize: 3}
+  g := Paginate(api.fetch, nil)
+  var results []int
+  AppendValues(g, &results)
+  if output := fmt.Sprintf("%v", results); output != "[0 1 2 3 4 5 6 7 8 9 10 11]" {
+    t.Errorf("Expected [0 1 2 3 4 5 6 7 8 9 10 11] got %v", output)
+  }
+  if err := g.(ErrStream).Err(); err != nil {
+    t.Errorf("Expected no error got %v", err)
+  }
+  if output := fmt.Sprintf("%v", api.tokens()); output != "[ 1 2 3]" {
+    t.Errorf("Expected [ 1 2 3] got %v", output)
+  }
+}
+
+func TestPaginateLazy(t *testing.T) {
+  api := &pagedAPI{pages: 100, pageSize: 3}
+  g := Paginate(api.fetch, nil)
+  var results []int
+  AppendValues(Slice(g, 0, 4), &results)
+  if output := fmt.Sprintf("%v", results); output != "[0 1 2 3]" {
+    t.Errorf("Expected [0 1 2 3] got %v", output)
+  }
+  if n := len(api.tokens()); n != 2 {
+    t.Errorf("Expected 2 fetches got %v", n)
+  }
+  g.Close()
+  if g.Next(new(int)) {
+    t.Error("Expected closed Generator to be empty.")
+  }
+}
+
+func TestPaginatePrefetch(t *testing.T) {
+  api := &pagedAPI{pages: 3, pageSize: 2, fetched: make(chan string, 10)}
+  g := Paginate(api.fetch, &PaginateOptions{Prefetch: true})
+  var x int
+  g.Next(&x)
+  // Page 2 is fetched while page 1 is still being read.
+  <-api.fetched
+  if token := <-api.fetched; token != "1" {
+    t.Errorf("Expected prefetch of page 1 got %v", token)
+  }
+  var results []int
+  AppendValues(g, &results)
+  if output := fmt.Sprintf("%v", results); output != "[1 2 3 4 5]" {
+    t.Errorf("Expected [1 2 3 4 5] got %v", output)
+  }
+  if n := len(api.tokens()); n != 3 {
+    t.Errorf("Expected 3 fetches got %v", n)
+  }
+}
+
+func TestPaginateRetry(t *testing.T) {
+  clock := newFakeClock()
+  api := &pagedAPI{pages: 2, pageSize: 2, failures: map[string]int{"1": 2}}
+  g := Paginate(
+      api.fetch,
+      &PaginateOptions{Retry: &RetryPolicy{MaxAttempts: 3, Backoff: time.Second, Clock: clock}})
+  go func() {
+    clock.WaitForWaiters(1)
+    clock.Advance(time.Second)
+    clock.WaitForWaiters(1)
+    clock.Advance(2 * time.Second)
+  }()
+  var results []int
+  AppendValues(g, &results)
+  if output := fmt.Sprintf("%v", results); output != "[0 1 2 3]" {
+    t.Errorf("Expected [0 1 2 3] got %v", output)
+  }
+  if err := g.(ErrStream).Err(); err != nil {
+    t.Errorf("Expected no error got %v", err)
+  }
+}
+
+func TestPaginateError(t *testing.T) {
+  api := &pagedAPI{pages: 3, pageSize: 2, failures: map[string]int{"1": 1}}
+  g := Paginate(api.fetch, &PaginateOptions{Prefetch: true})
+  var results []int
+  AppendValues(g, &results)
+  if output := fmt.Sprintf("%v", results); output != "[0 1]" {
+    t.Errorf("Expected [0 1] got %v", output)
+  }
+  if err := g.(ErrStream).Err(); err == nil || err.Error() != "Page 1 unavailable" {
+    t.Errorf("Expected 'Page 1 unavailable' got %v", err)
+  }
+}
+
+func TestPaginateEmptyPages(t *testing.T) {
+  fetch := func(token string) (interface{}, string, error) {
+    switch token {
+    case "":
+      return nil, "a", nil
+    case "a":
+      return []string{}, "b", nil
+    }
+    return []string{"last"}, "", nil
+  }
+  var results []string
+  AppendValues(Paginate(fetch, nil), &results)
+  if output := fmt.Sprintf("%v", results); output != "[last]" {
+    t.Errorf("Expected [last] got %v", output)
+  }
+}
+
+// pagedAPI serves ints pageSize at a time. Page tokens are page numbers
+// except for the first page whose token is empty.
+type pagedAPI struct {
+  pages int
+  pageSize int
+  // failures is how many times fetching a given token fails.
+  failures map[string]int
+  // fetched, if not nil, receives each token fetched.
+  fetched chan string
+  mu sync.Mutex
+  fetchedTokens []string
+}
+
+func (a *pagedAPI) fetch(token string) (interface{}, string, error) {
+  a.mu.Lock()
+  a.fetchedTokens = append(a.fetchedTokens, token)
+  if a.failures[token] > 0 {
+    a.failures[token]--
+    a.mu.Unlock()
+    return nil, "", errors.New("Page " + token + " unavailable")
+  }
+  a.mu.Unlock()
+  if a.fetched != nil {
+    a.fetched <- token
+  }
+  pageNo := 0
+  if token != "" {
+    pageNo, _ = strconv.Atoi(token)
+  }
+  var items []int
+  for i := 0; i < a.pageSize; i++ {
+    items = append(items, pageNo * a.pageSize + i)
+  }
+  next := ""
+  if pageNo + 1 < a.pages {
+    next = strconv.Itoa(pageNo + 1)
+  }
+  return items, next, nil
+}
+
+func (a *pagedAPI) tokens() []string {
+  a.mu.Lock()
+  defer a.mu.Unlock()
+  return append([]string(nil), a.fetchedTokens...)
+}
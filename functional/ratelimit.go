@@ -0,0 +1,253 @@
+package functional
+
+import (
+  "io"
+  "math"
+  "sync"
+  "time"
+)
+
+// RateLimit returns a Generator that emits the values of s no faster than
+// perSecond values per second on average as measured by clock. RateLimit
+// uses a token bucket that holds up to burst tokens and starts full, so up
+// to burst values may be emitted at once after a quiet period. Next reads
+// from s first and then waits for a token only if s emitted a value.
+// RateLimit panics if perSecond is not positive. If burst < 1, 1 is used.
+// If clock is nil, SystemClock is used. Closing the returned Generator
+// closes s if it is an io.Closer.
+func RateLimit(s Stream, perSecond float64, burst int, clock Clock) Generator {
+  if perSecond <= 0 {
+    panic("perSecond must be positive.")
+  }
+  if burst < 1 {
+    burst = 1
+  }
+  clock = clockOrDefault(clock)
+  return &rateLimitStream{
+      stream: s,
+      perSecond: perSecond,
+      burst: float64(burst),
+      clock: clock,
+      tokens: float64(burst),
+      last: clock.Now(),
+      streamCloser: newStreamCloser(s)}
+}
+
+// Throttle returns a Generator that emits the values of s dropping each
+// value that s emits less than interval after the last emitted value.
+// clock timestamps the values as s emits them; if clock is nil,
+// SystemClock is used. Closing the returned Generator closes s if it is
+// an io.Closer.
+func Throttle(s Stream, interval time.Duration, clock Clock) Generator {
+  return &throttleStream{
+      stream: s,
+      interval: interval,
+      clock: clockOrDefault(clock),
+      streamCloser: newStreamCloser(s)}
+}
+
+// Debounce returns a Generator of T that coalesces the values of s, a Stream
+// of T, that arrive in quick succession. Debounce emits a value of s only
+// once quiet has passed without s emitting another value, so of each run of
+// values less than quiet apart, only the last is emitted. The last value of
+// s is emitted as soon as s is exhausted. Debounce reads s on a separate
+// goroutine into values it creates with c, a Creater of T, and timestamps
+// them with clock; if clock is nil, SystemClock is used. copier, a Copier of
+// T, copies each value to the caller; if copier is nil, regular assignment
+// is used. Closing the returned Generator closes s, if s is an io.Closer,
+// while Debounce may be reading it, so such an s must allow concurrent Close
+// as described for Generator. If s is an ErrStream, the returned Generator's
+// Err method reports its error once the values run out.
+func Debounce(
+    s Stream, quiet time.Duration, clock Clock, c Creater,
+    copier Copier) Generator {
+  if copier == nil {
+    copier = assignCopier
+  }
+  d := &debouncer{
+      s: s,
+      quiet: quiet,
+      clock: clockOrDefault(clock),
+      copier: copier,
+      free: make(chan interface{}, 2),
+      values: make(chan timedValue),
+      done: make(chan struct{}),
+      readerDone: make(chan struct{})}
+  d.free <- c()
+  d.free <- c()
+  go d.read()
+  return d
+}
+
+type rateLimitStream struct {
+  stream Stream
+  perSecond float64
+  burst float64
+  clock Clock
+  // tokens is how many tokens were in the bucket at last.
+  tokens float64
+  last time.Time
+  *streamCloser
+}
+
+func (r *rateLimitStream) Next(ptr interface{}) bool {
+  if !r.stream.Next(ptr) {
+    return false
+  }
+  r.refill()
+  if r.tokens < 1 {
+    wait := math.Ceil((1 - r.tokens) / r.perSecond * float64(time.Second))
+    <-r.clock.After(time.Duration(wait))
+    r.refill()
+  }
+  r.tokens--
+  return true
+}
+
+func (r *rateLimitStream) refill() {
+  now := r.clock.Now()
+  r.tokens = math.Min(r.burst, r.tokens + now.Sub(r.last).Seconds() * r.perSecond)
+  r.last = now
+}
+
+type throttleStream struct {
+  stream Stream
+  interval time.Duration
+  clock Clock
+  emitted bool
+  last time.Time
+  *streamCloser
+}
+
+func (s *throttleStream) Next(ptr interface{}) bool {
+  for s.stream.Next(ptr) {
+    now := s.clock.Now()
+    if !s.emitted || now.Sub(s.last) >= s.interval {
+      s.emitted = true
+      s.last = now
+      return true
+    }
+  }
+  return false
+}
+
+// timedValue is a value that Debounce read along with when it was read.
+type timedValue struct {
+  ptr interface{}
+  at time.Time
+}
+
+type debouncer struct {
+  s Stream
+  quiet time.Duration
+  clock Clock
+  copier Copier
+  // free holds the values ready to be read into.
+  free chan interface{}
+  // values receives each value read from s. The reader closes values when
+  // s is exhausted.
+  values chan timedValue
+  done chan struct{}
+  readerDone chan struct{}
+  closeOnce sync.Once
+  closeErr error
+  // held is the latest value not yet emitted if holding is true.
+  held timedValue
+  holding bool
+  // exhausted is true once Next has seen values closed.
+  exhausted bool
+  err error
+}
+
+func (d *debouncer) Next(ptr interface{}) bool {
+  for {
+    select {
+    case <-d.done:
+      return false
+    default:
+    }
+    if !d.holding {
+      select {
+      case <-d.done:
+        return false
+      case value, ok := <-d.values:
+        if !ok {
+          d.exhausted = true
+          return false
+        }
+        d.held = value
+        d.holding = true
+      }
+      continue
+    }
+    wait := d.held.at.Add(d.quiet).Sub(d.clock.Now())
+    select {
+    case <-d.done:
+      return false
+    case value, ok := <-d.values:
+      if !ok {
+        d.exhausted = true
+        return d.emitHeld(ptr)
+      }
+      if value.at.Sub(d.held.at) >= d.quiet {
+        d.emitHeld(ptr)
+        d.held = value
+        d.holding = true
+        return true
+      }
+      d.free <- d.held.ptr
+      d.held = value
+    case <-d.clock.After(wait):
+      return d.emitHeld(ptr)
+    }
+  }
+}
+
+func (d *debouncer) emitHeld(ptr interface{}) bool {
+  d.copier(d.held.ptr, ptr)
+  d.free <- d.held.ptr
+  d.holding = false
+  return true
+}
+
+func (d *debouncer) Err() error {
+  if !d.exhausted || d.holding {
+    return nil
+  }
+  return d.err
+}
+
+func (d *debouncer) Close() error {
+  d.closeOnce.Do(func() {
+    close(d.done)
+    if c, ok := d.s.(io.Closer); ok {
+      d.closeErr = c.Close()
+    }
+    <-d.readerDone
+  })
+  return d.closeErr
+}
+
+func (d *debouncer) read() {
+  defer close(d.readerDone)
+  defer close(d.values)
+  for {
+    var ptr interface{}
+    select {
+    case <-d.done:
+      return
+    case ptr = <-d.free:
+    }
+    if !d.s.Next(ptr) {
+      if es, ok := d.s.(ErrStream); ok {
+        d.err = es.Err()
+      }
+      return
+    }
+    select {
+    case <-d.done:
+      return
+    case d.values <- timedValue{ptr, d.clock.Now()}:
+    }
+  }
+}
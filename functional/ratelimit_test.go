@@ -0,0 +1,212 @@
+package functional
+
+import (
+    "fmt"
+    "strings"
+    "testing"
+    "testing/iotest"
+    "time"
+)
+
+func TestRateLimit(t *testing.T) {
+  clock := newFakeClock()
+  g := RateLimit(Count(), 2.0, 3, clock)
+  var x int
+  // The bucket starts full.
+  for i := 0; i < 3; i++ {
+    if !g.Next(&x) || x != i {
+      t.Fatalf("Expected %v got %v", i, x)
+    }
+  }
+  start := clock.Now()
+  result := make(chan int)
+  go func() {
+    g.Next(&x)
+    result <- x
+  }()
+  clock.WaitForWaiters(1)
+  clock.Advance(500 * time.Millisecond)
+  if value := <-result; value != 3 {
+    t.Errorf("Expected 3 got %v", value)
+  }
+  if elapsed := clock.Now().Sub(start); elapsed != 500 * time.Millisecond {
+    t.Errorf("Expected 500ms got %v", elapsed)
+  }
+  // A long quiet period refills the bucket only up to burst.
+  clock.Advance(time.Hour)
+  for i := 4; i < 7; i++ {
+    if !g.Next(&x) || x != i {
+      t.Fatalf("Expected %v got %v", i, x)
+    }
+  }
+  go func() {
+    g.Next(&x)
+    result <- x
+  }()
+  clock.WaitForWaiters(1)
+  clock.Advance(500 * time.Millisecond)
+  if value := <-result; value != 7 {
+    t.Errorf("Expected 7 got %v", value)
+  }
+}
+
+func TestRateLimitExhausted(t *testing.T) {
+  clock := newFakeClock()
+  g := RateLimit(xrange(0, 1), 1.0, 1, clock)
+  var x int
+  if !g.Next(&x) || x != 0 {
+    t.Errorf("Expected 0 got %v", x)
+  }
+  // Finding out that there are no more values does not wait for a token.
+  if g.Next(&x) {
+    t.Error("Expected no more values.")
+  }
+}
+
+func TestRateLimitBadRate(t *testing.T) {
+  defer func() {
+    if recover() == nil {
+      t.Error("Expected panic for non-positive rate.")
+    }
+  }()
+  RateLimit(Count(), 0.0, 1, nil)
+}
+
+func TestRateLimitClose(t *testing.T) {
+  source := &closeCountingGenerator{Stream: xrange(0, 3)}
+  g := RateLimit(source, 1000.0, 0, nil)
+  var results []int
+  AppendValues(g, &results)
+  if output := fmt.Sprintf("%v", results); output != "[0 1 2]" {
+    t.Errorf("Expected [0 1 2] got %v", output)
+  }
+  g.Close()
+  if source.closed != 1 {
+    t.Errorf("Expected source closed once got %v", source.closed)
+  }
+}
+
+func TestThrottle(t *testing.T) {
+  clock := newFakeClock()
+  s := &clockedStream{
+      Stream: xrange(0, 7),
+      clock: clock,
+      gaps: []time.Duration{0, 400, 400, 300, 900, 1000, 100}}
+  var results []int
+  AppendValues(Throttle(s, time.Second, clock), &results)
+  if output := fmt.Sprintf("%v", results); output != "[0 3 5]" {
+    t.Errorf("Expected [0 3 5] got %v", output)
+  }
+}
+
+func TestDebounce(t *testing.T) {
+  clock := newFakeClock()
+  s := &clockedStream{
+      Stream: xrange(0, 7),
+      clock: clock,
+      gaps: []time.Duration{0, 400, 400, 2000, 999, 1000, 100}}
+  g := Debounce(s, time.Second, clock, func() interface{} { return new(int) }, nil)
+  defer g.Close()
+  var results []int
+  AppendValues(g, &results)
+  if output := fmt.Sprintf("%v", results); output != "[2 4 6]" {
+    t.Errorf("Expected [2 4 6] got %v", output)
+  }
+  if err := g.(ErrStream).Err(); err != nil {
+    t.Errorf("Expected no error got %v", err)
+  }
+}
+
+func TestDebounceQuiet(t *testing.T) {
+  clock := newFakeClock()
+  s := chanStream{values: make(chan int), asked: make(chan struct{}, 10)}
+  g := Debounce(s, time.Second, clock, func() interface{} { return new(int) }, nil)
+  result := make(chan int)
+  go func() {
+    var x int
+    g.Next(&x)
+    result <- x
+  }()
+  s.values <- 1
+  s.values <- 2
+  // Once the reader asks for a third value, Next has received 2.
+  for i := 0; i < 3; i++ {
+    <-s.asked
+  }
+  clock.WaitForWaiters(2)
+  clock.Advance(time.Second)
+  if value := <-result; value != 2 {
+    t.Errorf("Expected 2 got %v", value)
+  }
+  close(s.values)
+  var x int
+  if g.Next(&x) {
+    t.Error("Expected no more values.")
+  }
+  g.Close()
+}
+
+func TestDebounceCloseWhileWaiting(t *testing.T) {
+  name := writeTempFile(t, nil)
+  clock := newFakeClock()
+  source, err := FollowLines(name, &FollowOptions{Interval: time.Second, Clock: clock})
+  if err != nil {
+    t.Fatalf("Got error %v", err)
+  }
+  g := Debounce(source, time.Second, clock, func() interface{} { return new(string) }, nil)
+  // The reader waits for lines.
+  clock.WaitForWaiters(1)
+  if err := g.Close(); err != nil {
+    t.Errorf("Expected no error got %v", err)
+  }
+  if g.Next(new(string)) {
+    t.Error("Expected closed Generator to be empty.")
+  }
+}
+
+func TestDebounceError(t *testing.T) {
+  bad := ReadLines(iotest.TimeoutReader(iotest.OneByteReader(strings.NewReader("a\nb\n"))))
+  g := Debounce(bad, time.Hour, nil, func() interface{} { return new(string) }, nil)
+  defer g.Close()
+  var results []string
+  AppendValues(g, &results)
+  if output := strings.Join(results, ","); output != "a" {
+    t.Errorf("Expected 'a' got '%v'", output)
+  }
+  if err := g.(ErrStream).Err(); err != iotest.ErrTimeout {
+    t.Errorf("Expected timeout error got %v", err)
+  }
+}
+
+// clockedStream advances clock by the next gap in milliseconds before
+// emitting each value.
+type clockedStream struct {
+  Stream
+  clock *fakeClock
+  gaps []time.Duration
+}
+
+func (s *clockedStream) Next(ptr interface{}) bool {
+  if len(s.gaps) > 0 {
+    s.clock.Advance(s.gaps[0] * time.Millisecond)
+    s.gaps = s.gaps[1:]
+  }
+  return s.Stream.Next(ptr)
+}
+
+func TestDebounceCloseWhileGeneratorWaits(t *testing.T) {
+  values := make(chan int)
+  asked := make(chan struct{}, 10)
+  g := Debounce(
+      chanEmitter(values, asked),
+      time.Second,
+      newFakeClock(),
+      func() interface{} { return new(int) },
+      nil)
+  // The reader waits for a value.
+  <-asked
+  if err := g.Close(); err != nil {
+    t.Errorf("Expected no error got %v", err)
+  }
+  close(values)
+}
@@ -0,0 +1,255 @@
+package functional
+
+import (
+  "bufio"
+  "bytes"
+  "encoding"
+  "encoding/binary"
+  "encoding/gob"
+  "errors"
+  "hash/crc32"
+  "io"
+)
+
+const (
+  kRecordHeaderSize = 12
+  kMaxRecordSize = 64 * 1024 * 1024
+)
+
+var (
+  kRecordMagic = []byte{0xf5, 0x7e, 0x4d, 0x1c}
+)
+
+// RecordEncoder encodes values for WriteRecords.
+type RecordEncoder interface {
+  // Encode encodes the T value ptr points to. ptr is a *T.
+  Encode(ptr interface{}) ([]byte, error)
+}
+
+// RecordDecoder decodes values for ReadRecords.
+type RecordDecoder interface {
+  // Decode decodes data, which Encode of the corresponding RecordEncoder
+  // produced, storing the T value at ptr. ptr is a *T.
+  Decode(data []byte, ptr interface{}) error
+}
+
+// GobCodec is a RecordEncoder and RecordDecoder that uses encoding/gob.
+// Each record is self-contained, carrying its own type information, so that
+// a record can be decoded even if the records before it were lost.
+type GobCodec struct {
+}
+
+func (c GobCodec) Encode(ptr interface{}) ([]byte, error) {
+  var b bytes.Buffer
+  if err := gob.NewEncoder(&b).Encode(ptr); err != nil {
+    return nil, err
+  }
+  return b.Bytes(), nil
+}
+
+func (c GobCodec) Decode(data []byte, ptr interface{}) error {
+  return gob.NewDecoder(bytes.NewReader(data)).Decode(ptr)
+}
+
+// BinaryCodec is a RecordEncoder and RecordDecoder for values whose
+// pointers implement encoding.BinaryMarshaler and
+// encoding.BinaryUnmarshaler.
+type BinaryCodec struct {
+}
+
+func (c BinaryCodec) Encode(ptr interface{}) ([]byte, error) {
+  return ptr.(encoding.BinaryMarshaler).MarshalBinary()
+}
+
+func (c BinaryCodec) Decode(data []byte, ptr interface{}) error {
+  return ptr.(encoding.BinaryUnmarshaler).UnmarshalBinary(data)
+}
+
+// RecordWriter is a Consumer of T that writes the T values it consumes to
+// an io.Writer as length-prefixed binary records. Each record carries a
+// checksum so that ReadRecords can detect and skip corrupt records. If
+// encoding or writing fails, RecordWriter stops consuming. Once Consume
+// returns, Written and Err report the results.
+type RecordWriter struct {
+  // W is where the records are written.
+  W io.Writer
+  // Creater is a Creater of T. It creates the value that receives each
+  // consumed value.
+  Creater Creater
+  // Encoder encodes each T value. If nil, GobCodec is used.
+  Encoder RecordEncoder
+  // Written is the number of records written.
+  Written int
+  // Err is the first error encountered or nil if there was no error.
+  Err error
+}
+
+// WriteRecords returns a RecordWriter that writes to w. c is a Creater of
+// T. enc encodes each T value; if nil, GobCodec is used.
+func WriteRecords(w io.Writer, c Creater, enc RecordEncoder) *RecordWriter {
+  return &RecordWriter{W: w, Creater: c, Encoder: enc}
+}
+
+// Consume writes the values in s, a Stream of T, as records.
+func (w *RecordWriter) Consume(s Stream) {
+  enc := w.Encoder
+  if enc == nil {
+    enc = GobCodec{}
+  }
+  ptr := w.Creater()
+  var record []byte
+  for s.Next(ptr) {
+    payload, err := enc.Encode(ptr)
+    if err != nil {
+      w.Err = err
+      return
+    }
+    if len(payload) > kMaxRecordSize {
+      w.Err = errors.New("Record too large.")
+      return
+    }
+    record = appendRecord(record[:0], payload)
+    if _, err := w.W.Write(record); err != nil {
+      w.Err = err
+      return
+    }
+    w.Written++
+  }
+}
+
+// RecordReader is a Stream of T that reads records that a RecordWriter
+// wrote. When RecordReader finds a corrupt record, it skips ahead to the
+// next intact record and continues from there.
+type RecordReader struct {
+  r *bufio.Reader
+  dec RecordDecoder
+  pending []byte
+  payload []byte
+  synced bool
+  skipped int
+  err error
+  done bool
+}
+
+// ReadRecords returns the records in r as a Stream of T. dec decodes each
+// record; if nil, GobCodec is used.
+func ReadRecords(r io.Reader, dec RecordDecoder) *RecordReader {
+  if dec == nil {
+    dec = GobCodec{}
+  }
+  return &RecordReader{r: bufio.NewReader(r), dec: dec, synced: true}
+}
+
+// Next emits the next intact record. ptr is a *T.
+func (r *RecordReader) Next(ptr interface{}) bool {
+  if r.done {
+    return false
+  }
+  var header [kRecordHeaderSize]byte
+  for {
+    n, err := r.readFull(header[:])
+    if err == io.EOF || err == io.ErrUnexpectedEOF {
+      if n > 0 {
+        r.markCorrupt()
+      }
+      r.done = true
+      return false
+    }
+    if err != nil {
+      r.fail(err)
+      return false
+    }
+    if !bytes.Equal(header[:4], kRecordMagic) {
+      r.resync(header[:], nil)
+      continue
+    }
+    length := binary.BigEndian.Uint32(header[4:])
+    if length > kMaxRecordSize {
+      r.resync(header[:], nil)
+      continue
+    }
+    if cap(r.payload) < int(length) {
+      r.payload = make([]byte, length)
+    }
+    payload := r.payload[:length]
+    n, err = r.readFull(payload)
+    if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+      r.fail(err)
+      return false
+    }
+    if n < len(payload) || crc32.ChecksumIEEE(payload) != binary.BigEndian.Uint32(header[8:]) {
+      r.resync(header[:], payload[:n])
+      continue
+    }
+    r.synced = true
+    if err := r.dec.Decode(payload, ptr); err != nil {
+      r.fail(err)
+      return false
+    }
+    return true
+  }
+}
+
+// Err returns the error that stopped this RecordReader or nil if it reached
+// the end of its input normally. Corrupt records are not errors.
+func (r *RecordReader) Err() error {
+  return r.err
+}
+
+// Skipped returns how many times this RecordReader found corrupt data and
+// had to skip ahead to the next intact record.
+func (r *RecordReader) Skipped() int {
+  return r.skipped
+}
+
+func (r *RecordReader) fail(err error) {
+  r.err = err
+  r.done = true
+}
+
+func (r *RecordReader) markCorrupt() {
+  if r.synced {
+    r.skipped++
+    r.synced = false
+  }
+}
+
+// resync arranges for the search for the next record to resume at the
+// first possible record start past the beginning of the corrupt record
+// whose header and payload were just read.
+func (r *RecordReader) resync(header, payload []byte) {
+  r.markCorrupt()
+  rest := make([]byte, 0, len(header) - 1 + len(payload) + len(r.pending))
+  rest = append(rest, header[1:]...)
+  rest = append(rest, payload...)
+  rest = append(rest, r.pending...)
+  if i := bytes.Index(rest, kRecordMagic); i >= 0 {
+    rest = rest[i:]
+  } else if len(rest) >= len(kRecordMagic) {
+    // The start of the magic number may be at the very end.
+    rest = rest[len(rest) - len(kRecordMagic) + 1:]
+  }
+  r.pending = rest
+}
+
+// appendRecord appends payload framed as a record to buf and returns the
+// extended buffer.
+func appendRecord(buf, payload []byte) []byte {
+  buf = append(buf, kRecordMagic...)
+  buf = binary.BigEndian.AppendUint32(buf, uint32(len(payload)))
+  buf = binary.BigEndian.AppendUint32(buf, crc32.ChecksumIEEE(payload))
+  return append(buf, payload...)
+}
+
+func (r *RecordReader) readFull(p []byte) (int, error) {
+  n := copy(p, r.pending)
+  r.pending = r.pending[n:]
+  if n == len(p) {
+    return n, nil
+  }
+  m, err := io.ReadFull(r.r, p[n:])
+  if err == io.EOF && n > 0 {
+    err = io.ErrUnexpectedEOF
+  }
+  return n + m, err
+}
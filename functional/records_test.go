@@ -0,0 +1,159 @@
+package functional
+
+import (
+    "bytes"
+    "encoding/binary"
+    "errors"
+    "fmt"
+    "testing"
+)
+
+var (
+  writeError = errors.New("error writing.")
+)
+
+func TestRecords(t *testing.T) {
+  var b bytes.Buffer
+  w := WriteRecords(&b, func() interface{} { return new(record) }, nil)
+  w.Consume(NewStreamFromValues(records(3)))
+  if w.Err != nil || w.Written != 3 {
+    t.Errorf("Expected 3 records written got %v, %v", w.Written, w.Err)
+  }
+  r := ReadRecords(&b, nil)
+  var results []record
+  AppendValues(r, &results)
+  if output := fmt.Sprintf("%v", results); output != "[{0 r0} {1 r1} {2 r2}]" {
+    t.Errorf("Expected [{0 r0} {1 r1} {2 r2}] got %v", output)
+  }
+  if r.Err() != nil || r.Skipped() != 0 {
+    t.Errorf("Expected no error or skipping got %v, %v", r.Err(), r.Skipped())
+  }
+}
+
+func TestRecordsEmpty(t *testing.T) {
+  r := ReadRecords(bytes.NewReader(nil), nil)
+  if r.Next(new(record)) || r.Err() != nil {
+    t.Error("Expected empty Stream.")
+  }
+}
+
+func TestRecordsBinaryCodec(t *testing.T) {
+  var b bytes.Buffer
+  w := WriteRecords(&b, func() interface{} { return new(point) }, BinaryCodec{})
+  w.Consume(NewStreamFromValues([]point{{1, 2}, {-3, 4}}))
+  if b.Len() != 2 * (kRecordHeaderSize + 16) {
+    t.Errorf("Expected binary encoding got %v bytes", b.Len())
+  }
+  var results []point
+  AppendValues(ReadRecords(&b, BinaryCodec{}), &results)
+  if output := fmt.Sprintf("%v", results); output != "[{1 2} {-3 4}]" {
+    t.Errorf("Expected [{1 2} {-3 4}] got %v", output)
+  }
+}
+
+func TestRecordsCorrupt(t *testing.T) {
+  var b bytes.Buffer
+  WriteRecords(&b, func() interface{} { return new(record) }, nil).Consume(
+      NewStreamFromValues(records(4)))
+  data := b.Bytes()
+  recordSize := len(data) / 4
+  // Damage the payload of the second record and the magic number of the
+  // third.
+  data[recordSize + kRecordHeaderSize + 5] ^= 0xff
+  data[2 * recordSize] ^= 0xff
+  r := ReadRecords(bytes.NewReader(data), nil)
+  var results []record
+  AppendValues(r, &results)
+  if output := fmt.Sprintf("%v", results); output != "[{0 r0} {3 r3}]" {
+    t.Errorf("Expected [{0 r0} {3 r3}] got %v", output)
+  }
+  if r.Skipped() != 1 || r.Err() != nil {
+    t.Errorf("Expected 1 skip and no error got %v, %v", r.Skipped(), r.Err())
+  }
+}
+
+func TestRecordsGarbage(t *testing.T) {
+  var b bytes.Buffer
+  s := NewStreamFromValues(records(2))
+  w := WriteRecords(&b, func() interface{} { return new(record) }, nil)
+  w.Consume(Slice(s, 0, 1))
+  b.Write(kRecordMagic[:2])
+  b.WriteString("garbage")
+  b.Write(kRecordMagic)
+  w.Consume(s)
+  b.Write(kRecordMagic)
+  b.Write(binary.BigEndian.AppendUint32(nil, 1000))
+  r := ReadRecords(&b, nil)
+  var results []record
+  AppendValues(r, &results)
+  if output := fmt.Sprintf("%v", results); output != "[{0 r0} {1 r1}]" {
+    t.Errorf("Expected [{0 r0} {1 r1}] got %v", output)
+  }
+  if r.Skipped() != 2 || r.Err() != nil {
+    t.Errorf("Expected 2 skips and no error got %v, %v", r.Skipped(), r.Err())
+  }
+}
+
+func TestRecordsDecodeError(t *testing.T) {
+  var b bytes.Buffer
+  WriteRecords(&b, func() interface{} { return new(record) }, nil).Consume(
+      NewStreamFromValues(records(1)))
+  r := ReadRecords(&b, nil)
+  if r.Next(new(point)) {
+    t.Error("Expected decoding into wrong type to fail.")
+  }
+  if r.Err() == nil {
+    t.Error("Expected an error.")
+  }
+}
+
+func TestRecordsWriteError(t *testing.T) {
+  w := WriteRecords(errWriter{}, func() interface{} { return new(record) }, nil)
+  s := NewStreamFromValues(records(2))
+  w.Consume(s)
+  if w.Err != writeError || w.Written != 0 {
+    t.Errorf("Expected writeError got %v", w.Err)
+  }
+  if !s.Next(new(record)) {
+    t.Error("RecordWriter should stop consuming after an error.")
+  }
+}
+
+type record struct {
+  Id int
+  Name string
+}
+
+func records(n int) []record {
+  result := make([]record, n)
+  for i := range result {
+    result[i] = record{i, fmt.Sprintf("r%d", i)}
+  }
+  return result
+}
+
+type point struct {
+  X int64
+  Y int64
+}
+
+func (p *point) MarshalBinary() ([]byte, error) {
+  result := binary.BigEndian.AppendUint64(nil, uint64(p.X))
+  return binary.BigEndian.AppendUint64(result, uint64(p.Y)), nil
+}
+
+func (p *point) UnmarshalBinary(data []byte) error {
+  if len(data) != 16 {
+    return errors.New("bad point")
+  }
+  p.X = int64(binary.BigEndian.Uint64(data))
+  p.Y = int64(binary.BigEndian.Uint64(data[8:]))
+  return nil
+}
+
+type errWriter struct {
+}
+
+func (w errWriter) Write(p []byte) (int, error) {
+  return 0, writeError
+}
@@ -0,0 +1,276 @@
+package functional
+
+import (
+  "context"
+  "encoding/binary"
+  "errors"
+  "io"
+  "net"
+  "sync"
+)
+
+const (
+  kDefaultWindow = 64
+)
+
+// Message kinds in the protocol between StreamServer and RemoteStream.
+// Each message is a record as WriteRecords writes it whose payload starts
+// with the message kind.
+const (
+  // kMsgValue carries a gob encoded value from server to client.
+  kMsgValue byte = iota + 1
+  // kMsgEnd tells the client there are no more values. The rest of the
+  // payload is the error message, if any.
+  kMsgEnd
+  // kMsgCredit lets the server send more values. The rest of the payload
+  // is the number of values as a uvarint.
+  kMsgCredit
+  // kMsgCancel tells the server to stop sending values.
+  kMsgCancel
+)
+
+// StreamServer serves Streams to RemoteStream clients. For each connection,
+// StreamServer creates a new Stream and sends its values to the client,
+// but only as many values as the client has asked for.
+type StreamServer struct {
+  factory func() Stream
+  creater Creater
+}
+
+// NewStreamServer returns a new StreamServer. factory returns a new Stream
+// of T for each connection; if the Stream is also an io.Closer,
+// StreamServer closes it when the connection ends. StreamServer also closes
+// it as soon as the client closes its RemoteStream or goes away, even while
+// the Stream waits for a value, so such a Stream must allow concurrent
+// Close as described for Generator. c is a Creater of T.
+// T values are encoded with encoding/gob.
+func NewStreamServer(factory func() Stream, c Creater) *StreamServer {
+  return &StreamServer{factory: factory, creater: c}
+}
+
+// Serve accepts connections on l serving each one on its own goroutine.
+// Serve returns when l.Accept fails, say because l was closed.
+func (s *StreamServer) Serve(l net.Listener) error {
+  for {
+    conn, err := l.Accept()
+    if err != nil {
+      return err
+    }
+    go s.ServeConn(conn)
+  }
+}
+
+// ServeConn serves a single connection, closing it when done.
+func (s *StreamServer) ServeConn(conn net.Conn) {
+  defer conn.Close()
+  stream := s.factory()
+  ctx, cancel := context.WithCancel(context.Background())
+  defer cancel()
+  if c, ok := stream.(io.Closer); ok {
+    defer closeOnDone(ctx, c)()
+  }
+  credits := newCreditCounter()
+  go readClientMessages(conn, credits, cancel)
+  ptr := s.creater()
+  var buf []byte
+  for credits.take() {
+    if !stream.Next(ptr) {
+      var errMessage string
+      if es, ok := stream.(ErrStream); ok && es.Err() != nil {
+        errMessage = es.Err().Error()
+      }
+      writeMessage(conn, buf, kMsgEnd, []byte(errMessage))
+      return
+    }
+    payload, err := GobCodec{}.Encode(ptr)
+    if err != nil {
+      writeMessage(conn, buf, kMsgEnd, []byte(err.Error()))
+      return
+    }
+    if buf, err = writeMessage(conn, buf, kMsgValue, payload); err != nil {
+      return
+    }
+  }
+}
+
+// NewRemoteStream returns a Generator that emits the values of the Stream
+// that a StreamServer serves over conn. window is how many values the
+// server may send ahead of the client; if window <= 0, 64 is used. If the
+// connection fails, or if the Stream on the server stops because of an
+// error, the Generator's Err method, from ErrStream, reports the error.
+// Closing the Generator tells the server to stop and closes conn.
+func NewRemoteStream(conn net.Conn, window int) Generator {
+  if window <= 0 {
+    window = kDefaultWindow
+  }
+  return &remoteStream{conn: conn, messages: ReadRecords(conn, rawCodec{}), window: window}
+}
+
+// DialStream connects to a StreamServer at address on the named network
+// and returns a RemoteStream as NewRemoteStream does.
+func DialStream(network, address string, window int) (Generator, error) {
+  conn, err := net.Dial(network, address)
+  if err != nil {
+    return nil, err
+  }
+  return NewRemoteStream(conn, window), nil
+}
+
+type remoteStream struct {
+  conn net.Conn
+  messages *RecordReader
+  window int
+  // outstanding is the number of values the server may still send.
+  outstanding int
+  message []byte
+  buf []byte
+  err error
+  done bool
+  closed bool
+}
+
+func (r *remoteStream) Next(ptr interface{}) bool {
+  if r.done || r.closed {
+    return false
+  }
+  if r.outstanding <= r.window / 2 {
+    var err error
+    credit := binary.AppendUvarint(nil, uint64(r.window - r.outstanding))
+    if r.buf, err = writeMessage(r.conn, r.buf, kMsgCredit, credit); err != nil {
+      return r.fail(err)
+    }
+    r.outstanding = r.window
+  }
+  if !r.messages.Next(&r.message) {
+    err := r.messages.Err()
+    if err == nil {
+      err = io.ErrUnexpectedEOF
+    }
+    return r.fail(err)
+  }
+  switch r.message[0] {
+  case kMsgValue:
+    r.outstanding--
+    if err := (GobCodec{}).Decode(r.message[1:], ptr); err != nil {
+      return r.fail(err)
+    }
+    return true
+  case kMsgEnd:
+    if len(r.message) > 1 {
+      return r.fail(errors.New(string(r.message[1:])))
+    }
+    r.done = true
+    return false
+  }
+  return r.fail(errors.New("Unexpected message from StreamServer."))
+}
+
+func (r *remoteStream) Err() error {
+  return r.err
+}
+
+func (r *remoteStream) Close() error {
+  if r.closed {
+    return nil
+  }
+  r.closed = true
+  if !r.done {
+    writeMessage(r.conn, r.buf, kMsgCancel, nil)
+  }
+  return r.conn.Close()
+}
+
+func (r *remoteStream) fail(err error) bool {
+  r.err = err
+  r.done = true
+  return false
+}
+
+// creditCounter tracks how many values a client has asked for.
+type creditCounter struct {
+  mu sync.Mutex
+  cond *sync.Cond
+  credits uint64
+  cancelled bool
+}
+
+func newCreditCounter() *creditCounter {
+  result := &creditCounter{}
+  result.cond = sync.NewCond(&result.mu)
+  return result
+}
+
+func (c *creditCounter) grant(n uint64) {
+  c.mu.Lock()
+  defer c.mu.Unlock()
+  c.credits += n
+  c.cond.Broadcast()
+}
+
+func (c *creditCounter) cancel() {
+  c.mu.Lock()
+  defer c.mu.Unlock()
+  c.cancelled = true
+  c.cond.Broadcast()
+}
+
+// take waits for a credit and uses it. take returns false if the client
+// cancelled.
+func (c *creditCounter) take() bool {
+  c.mu.Lock()
+  defer c.mu.Unlock()
+  for c.credits == 0 && !c.cancelled {
+    c.cond.Wait()
+  }
+  if c.cancelled {
+    return false
+  }
+  c.credits--
+  return true
+}
+
+// readClientMessages reads the messages from the client on conn. When the
+// client cancels or goes away, readClientMessages cancels credits and calls
+// cancel.
+func readClientMessages(conn net.Conn, credits *creditCounter, cancel func()) {
+  defer cancel()
+  defer credits.cancel()
+  messages := ReadRecords(conn, rawCodec{})
+  var message []byte
+  for messages.Next(&message) {
+    switch message[0] {
+    case kMsgCredit:
+      n, size := binary.Uvarint(message[1:])
+      if size <= 0 {
+        return
+      }
+      credits.grant(n)
+    default:
+      return
+    }
+  }
+}
+
+// writeMessage writes a message to w using buf as scratch space. It
+// returns buf so that it can be re-used.
+func writeMessage(w io.Writer, buf []byte, kind byte, body []byte) ([]byte, error) {
+  payload := make([]byte, 0, len(body) + 1)
+  payload = append(append(payload, kind), body...)
+  buf = appendRecord(buf[:0], payload)
+  _, err := w.Write(buf)
+  return buf, err
+}
+
+// rawCodec is a RecordDecoder that emits a record's payload as is to a
+// *[]byte re-using its capacity.
+type rawCodec struct {
+}
+
+func (c rawCodec) Decode(data []byte, ptr interface{}) error {
+  p := ptr.(*[]byte)
+  *p = append((*p)[:0], data...)
+  if len(*p) == 0 {
+    return errors.New("Empty message.")
+  }
+  return nil
+}
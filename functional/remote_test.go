@@ -0,0 +1,196 @@
+package functional
+
+import (
+    "fmt"
+    "net"
+    "strings"
+    "sync"
+    "testing"
+    "testing/iotest"
+    "time"
+)
+
+func TestRemoteStream(t *testing.T) {
+  server := NewStreamServer(
+      func() Stream { return NewStreamFromValues(records(100)) },
+      func() interface{} { return new(record) })
+  client, serverConn := net.Pipe()
+  go server.ServeConn(serverConn)
+  g := NewRemoteStream(client, 8)
+  var results []record
+  AppendValues(g, &results)
+  if len(results) != 100 || results[99].Name != "r99" {
+    t.Errorf("Expected 100 records got %v", len(results))
+  }
+  if err := g.(ErrStream).Err(); err != nil {
+    t.Errorf("Expected no error got %v", err)
+  }
+  g.Close()
+}
+
+func TestRemoteStreamFlowControlAndClose(t *testing.T) {
+  source := &countingGenerator{}
+  server := NewStreamServer(
+      func() Stream { return source },
+      func() interface{} { return new(int) })
+  client, serverConn := net.Pipe()
+  served := make(chan struct{})
+  go func() {
+    server.ServeConn(serverConn)
+    close(served)
+  }()
+  g := NewRemoteStream(client, 4)
+  var results []int
+  AppendValues(Slice(g, 0, 3), &results)
+  if output := fmt.Sprintf("%v", results); output != "[0 1 2]" {
+    t.Errorf("Expected [0 1 2] got %v", output)
+  }
+  g.Close()
+  <-served
+  if n := source.count(); n > 4 {
+    t.Errorf("Expected at most 4 values read on server got %v", n)
+  }
+  if !source.isClosed() {
+    t.Error("Expected server to close its Stream.")
+  }
+  if g.Next(new(int)) {
+    t.Error("Expected closed Generator to be empty.")
+  }
+}
+
+func TestRemoteStreamCloseWhileServerWaits(t *testing.T) {
+  name := writeTempFile(t, []byte("a\n"))
+  clock := newFakeClock()
+  source, err := FollowLines(name, &FollowOptions{Interval: time.Second, Clock: clock})
+  if err != nil {
+    t.Fatalf("Got error %v", err)
+  }
+  server := NewStreamServer(
+      func() Stream { return source },
+      func() interface{} { return new(string) })
+  client, serverConn := net.Pipe()
+  served := make(chan struct{})
+  go func() {
+    server.ServeConn(serverConn)
+    close(served)
+  }()
+  g := NewRemoteStream(client, 4)
+  var line string
+  if !g.Next(&line) || line != "a" {
+    t.Errorf("Expected 'a' got '%v'", line)
+  }
+  // The server's Stream waits for more lines.
+  clock.WaitForWaiters(1)
+  g.Close()
+  <-served
+  if source.Next(&line) {
+    t.Error("Expected server to close its Stream.")
+  }
+}
+
+func TestRemoteStreamError(t *testing.T) {
+  server := NewStreamServer(
+      func() Stream {
+        return ReadLines(iotest.TimeoutReader(iotest.OneByteReader(strings.NewReader("a\nb\n"))))
+      },
+      func() interface{} { return new(string) })
+  client, serverConn := net.Pipe()
+  go server.ServeConn(serverConn)
+  g := NewRemoteStream(client, 0)
+  defer g.Close()
+  var results []string
+  AppendValues(g, &results)
+  if output := strings.Join(results, ","); output != "a" {
+    t.Errorf("Expected 'a' got '%v'", output)
+  }
+  if err := g.(ErrStream).Err(); err == nil || err.Error() != iotest.ErrTimeout.Error() {
+    t.Errorf("Expected timeout error got %v", err)
+  }
+}
+
+func TestRemoteStreamTCP(t *testing.T) {
+  l, err := net.Listen("tcp", "127.0.0.1:0")
+  if err != nil {
+    t.Skipf("Cannot listen on localhost: %v", err)
+  }
+  defer l.Close()
+  server := NewStreamServer(
+      func() Stream { return xrange(0, 5) },
+      func() interface{} { return new(int) })
+  go server.Serve(l)
+  for i := 0; i < 2; i++ {
+    g, err := DialStream("tcp", l.Addr().String(), 2)
+    if err != nil {
+      t.Fatalf("Got error %v", err)
+    }
+    var results []int
+    AppendValues(g, &results)
+    if output := fmt.Sprintf("%v", results); output != "[0 1 2 3 4]" {
+      t.Errorf("Expected [0 1 2 3 4] got %v", output)
+    }
+    g.Close()
+  }
+}
+
+func TestRemoteStreamConnectionLost(t *testing.T) {
+  client, serverConn := net.Pipe()
+  go func() {
+    var b []byte
+    b = appendRecord(b, append([]byte{kMsgValue}, mustGobEncode(t, 7)...))
+    serverConn.Read(make([]byte, 100))
+    serverConn.Write(b)
+    serverConn.Close()
+  }()
+  g := NewRemoteStream(client, 4)
+  var results []int
+  AppendValues(g, &results)
+  if output := fmt.Sprintf("%v", results); output != "[7]" {
+    t.Errorf("Expected [7] got %v", output)
+  }
+  if g.(ErrStream).Err() == nil {
+    t.Error("Expected an error.")
+  }
+}
+
+// countingGenerator is an infinite Generator of int that counts how many
+// values it emitted and whether it was closed.
+type countingGenerator struct {
+  mu sync.Mutex
+  n int
+  closed bool
+}
+
+func (g *countingGenerator) Next(ptr interface{}) bool {
+  g.mu.Lock()
+  defer g.mu.Unlock()
+  *ptr.(*int) = g.n
+  g.n++
+  return true
+}
+
+func (g *countingGenerator) Close() error {
+  g.mu.Lock()
+  defer g.mu.Unlock()
+  g.closed = true
+  return nil
+}
+
+func (g *countingGenerator) count() int {
+  g.mu.Lock()
+  defer g.mu.Unlock()
+  return g.n
+}
+
+func (g *countingGenerator) isClosed() bool {
+  g.mu.Lock()
+  defer g.mu.Unlock()
+  return g.closed
+}
+
+func mustGobEncode(t *testing.T, x int) []byte {
+  result, err := GobCodec{}.Encode(&x)
+  if err != nil {
+    t.Fatalf("Got error %v", err)
+  }
+  return result
+}
@@ -0,0 +1,75 @@
+package functional
+
+// Resumable returns a Generator of T that emits the values of a source
+// that may fail part way through. open opens the source starting at
+// position, the number of values already emitted, returning a Generator of
+// T. The source fails when opening it fails or when its Next returns false
+// and its Err method, from ErrStream, reports an error. When the source
+// fails, Resumable closes it and, after waiting as policy says, opens it
+// again where it left off. policy.MaxAttempts limits consecutive failures;
+// the count starts over each time the source emits a value. When policy
+// gives up, Next returns false and the returned Generator's Err method
+// reports the last error. If policy is nil, failures are not retried.
+// Closing the returned Generator closes the current source.
+func Resumable(open func(position int) (Generator, error), policy *RetryPolicy) Generator {
+  return &resumable{open: open, policy: policy}
+}
+
+type resumable struct {
+  open func(position int) (Generator, error)
+  policy *RetryPolicy
+  source Generator
+  position int
+  failures int
+  err error
+  done bool
+}
+
+func (r *resumable) Next(ptr interface{}) bool {
+  for !r.done {
+    if r.source == nil {
+      source, err := r.open(r.position)
+      if err != nil {
+        r.fail(err)
+        continue
+      }
+      r.source = source
+    }
+    if r.source.Next(ptr) {
+      r.position++
+      r.failures = 0
+      return true
+    }
+    es, ok := r.source.(ErrStream)
+    if !ok || es.Err() == nil {
+      r.done = true
+      break
+    }
+    r.source.Close()
+    r.source = nil
+    r.fail(es.Err())
+  }
+  return false
+}
+
+func (r *resumable) Err() error {
+  return r.err
+}
+
+func (r *resumable) Close() error {
+  r.done = true
+  if r.source == nil {
+    return nil
+  }
+  source := r.source
+  r.source = nil
+  return source.Close()
+}
+
+func (r *resumable) fail(err error) {
+  r.failures++
+  if !r.policy.wait(nil, r.failures) {
+    r.err = err
+    r.done = true
+  }
+}
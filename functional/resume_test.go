@@ -0,0 +1,168 @@
+package functional
+
+import (
+    "errors"
+    "fmt"
+    "testing"
+    "time"
+)
+
+func TestResumable(t *testing.T) {
+  clock := newFakeClock()
+  source := &flakySource{n: 10, failAt: map[int]int{3: 1, 7: 2}}
+  g := Resumable(
+      source.open,
+      &RetryPolicy{MaxAttempts: 3, Backoff: time.Second, Clock: clock})
+  go func() {
+    // One wait after failing at 3, two waits after failing at 7.
+    for _, d := range []time.Duration{time.Second, time.Second, 2 * time.Second} {
+      clock.WaitForWaiters(1)
+      clock.Advance(d)
+    }
+  }()
+  doubled := Map(
+      NewMapper(func(src, dest interface{}) bool {
+        *dest.(*int) = *src.(*int) * 2
+        return true
+      }),
+      g,
+      new(int))
+  var results []int
+  AppendValues(doubled, &results)
+  if output := fmt.Sprintf("%v", results); output != "[0 2 4 6 8 10 12 14 16 18]" {
+    t.Errorf("Expected [0 2 4 6 8 10 12 14 16 18] got %v", output)
+  }
+  if err := g.(ErrStream).Err(); err != nil {
+    t.Errorf("Expected no error got %v", err)
+  }
+  if output := fmt.Sprintf("%v", source.opens); output != "[0 3 7 7]" {
+    t.Errorf("Expected opens at [0 3 7 7] got %v", output)
+  }
+  g.Close()
+  if source.openCount != 0 {
+    t.Errorf("Expected all sources closed got %v open", source.openCount)
+  }
+}
+
+func TestResumableGivesUp(t *testing.T) {
+  clock := newFakeClock()
+  source := &flakySource{n: 10, failAt: map[int]int{4: 5}}
+  g := Resumable(
+      source.open,
+      &RetryPolicy{MaxAttempts: 2, Backoff: time.Second, Clock: clock})
+  go func() {
+    clock.WaitForWaiters(1)
+    clock.Advance(time.Second)
+  }()
+  var results []int
+  AppendValues(g, &results)
+  if output := fmt.Sprintf("%v", results); output != "[0 1 2 3]" {
+    t.Errorf("Expected [0 1 2 3] got %v", output)
+  }
+  if err := g.(ErrStream).Err(); err == nil || err.Error() != "Failed at 4" {
+    t.Errorf("Expected 'Failed at 4' got %v", err)
+  }
+  if g.Next(new(int)) {
+    t.Error("Expected no more values.")
+  }
+}
+
+func TestResumableOpenError(t *testing.T) {
+  clock := newFakeClock()
+  attempts := 0
+  open := func(position int) (Generator, error) {
+    attempts++
+    if attempts < 3 {
+      return nil, errors.New("Connection refused")
+    }
+    return StreamToGenerator(Slice(Count(), position, 3), nopCloser{}), nil
+  }
+  g := Resumable(open, &RetryPolicy{MaxAttempts: 3, Backoff: time.Second, Clock: clock})
+  go func() {
+    clock.WaitForWaiters(1)
+    clock.Advance(time.Second)
+    clock.WaitForWaiters(1)
+    clock.Advance(2 * time.Second)
+  }()
+  var results []int
+  AppendValues(g, &results)
+  if output := fmt.Sprintf("%v", results); output != "[0 1 2]" {
+    t.Errorf("Expected [0 1 2] got %v", output)
+  }
+}
+
+func TestResumableNoPolicy(t *testing.T) {
+  source := &flakySource{n: 10, failAt: map[int]int{2: 1}}
+  g := Resumable(source.open, nil)
+  var results []int
+  AppendValues(g, &results)
+  if output := fmt.Sprintf("%v", results); output != "[0 1]" {
+    t.Errorf("Expected [0 1] got %v", output)
+  }
+  if g.(ErrStream).Err() == nil {
+    t.Error("Expected an error.")
+  }
+  g.Close()
+  if source.openCount != 0 {
+    t.Errorf("Expected all sources closed got %v open", source.openCount)
+  }
+}
+
+func TestRetryPolicyJitter(t *testing.T) {
+  p := &RetryPolicy{Jitter: 0.5}
+  distinct := make(map[time.Duration]bool)
+  for i := 0; i < 100; i++ {
+    d := p.jitter(10 * time.Second)
+    if d < 5 * time.Second || d > 10 * time.Second {
+      t.Fatalf("Expected wait between 5s and 10s got %v", d)
+    }
+    distinct[d] = true
+  }
+  if len(distinct) < 2 {
+    t.Error("Expected waits to vary.")
+  }
+}
+
+// flakySource emits 0 through n-1 from any position. failAt maps a
+// position to how many more times reading it fails.
+type flakySource struct {
+  n int
+  failAt map[int]int
+  opens []int
+  openCount int
+}
+
+func (f *flakySource) open(position int) (Generator, error) {
+  f.opens = append(f.opens, position)
+  f.openCount++
+  return &flakyGenerator{source: f, position: position}, nil
+}
+
+type flakyGenerator struct {
+  source *flakySource
+  position int
+  err error
+}
+
+func (g *flakyGenerator) Next(ptr interface{}) bool {
+  if g.err != nil || g.position == g.source.n {
+    return false
+  }
+  if g.source.failAt[g.position] > 0 {
+    g.source.failAt[g.position]--
+    g.err = fmt.Errorf("Failed at %d", g.position)
+    return false
+  }
+  *ptr.(*int) = g.position
+  g.position++
+  return true
+}
+
+func (g *flakyGenerator) Err() error {
+  return g.err
+}
+
+func (g *flakyGenerator) Close() error {
+  g.source.openCount--
+  return nil
+}
@@ -0,0 +1,75 @@
+package functional
+
+import (
+  "math/rand"
+  "time"
+)
+
+// RetryPolicy says how to retry an operation that fails. A nil
+// *RetryPolicy means do not retry.
+type RetryPolicy struct {
+  // MaxAttempts is the most times to try the operation including the
+  // first time. If MaxAttempts <= 0, the operation is tried only once.
+  MaxAttempts int
+  // Backoff is how long to wait before the first retry. The wait doubles
+  // with each retry after that.
+  Backoff time.Duration
+  // MaxBackoff, if positive, is the longest to wait between retries.
+  MaxBackoff time.Duration
+  // Jitter, between 0 and 1, randomly shortens each wait by up to this
+  // fraction so that many clients retrying at once spread out.
+  Jitter float64
+  // Clock is used for waiting between retries. If nil, SystemClock is used.
+  Clock Clock
+}
+
+// delay returns how long to wait after the given number of failed
+// attempts.
+func (p *RetryPolicy) delay(failures int) time.Duration {
+  result := p.Backoff
+  for i := 1; i < failures; i++ {
+    if p.MaxBackoff > 0 && result >= p.MaxBackoff {
+      break
+    }
+    result *= 2
+  }
+  if p.MaxBackoff > 0 && result > p.MaxBackoff {
+    result = p.MaxBackoff
+  }
+  return result
+}
+
+// jitter randomly shortens d by up to Jitter.
+func (p *RetryPolicy) jitter(d time.Duration) time.Duration {
+  if p.Jitter <= 0 {
+    return d
+  }
+  return d - time.Duration(float64(d) * p.Jitter * rand.Float64())
+}
+
+// wait waits before retrying after the given number of failed attempts.
+// wait returns false without waiting if there should be no more attempts
+// or if done is closed while waiting.
+func (p *RetryPolicy) wait(done <-chan struct{}, failures int) bool {
+  if p == nil || failures >= p.MaxAttempts {
+    return false
+  }
+  select {
+  case <-done:
+    return false
+  case <-clockOrDefault(p.Clock).After(p.jitter(p.delay(failures))):
+    return true
+  }
+}
+
+// do calls f until it succeeds or until it has been called MaxAttempts
+// times, waiting between calls. do gives up early if done is closed while
+// it waits. do returns the error from the last call to f.
+func (p *RetryPolicy) do(done <-chan struct{}, f func() error) error {
+  for failures := 1; ; failures++ {
+    err := f()
+    if err == nil || !p.wait(done, failures) {
+      return err
+    }
+  }
+}
@@ -0,0 +1,66 @@
+package functional
+
+import (
+    "errors"
+    "fmt"
+    "testing"
+    "time"
+)
+
+func TestRetryPolicyDelay(t *testing.T) {
+  p := &RetryPolicy{Backoff: time.Second, MaxBackoff: 5 * time.Second}
+  var delays []time.Duration
+  for i := 1; i <= 5; i++ {
+    delays = append(delays, p.delay(i))
+  }
+  if output := fmt.Sprintf("%v", delays); output != "[1s 2s 4s 5s 5s]" {
+    t.Errorf("Expected [1s 2s 4s 5s 5s] got %v", output)
+  }
+}
+
+func TestRetryPolicyDo(t *testing.T) {
+  clock := newFakeClock()
+  p := &RetryPolicy{MaxAttempts: 3, Backoff: time.Second, Clock: clock}
+  calls := 0
+  failing := func() error {
+    calls++
+    return errors.New("failed")
+  }
+  result := make(chan error)
+  go func() {
+    result <- p.do(nil, failing)
+  }()
+  clock.WaitForWaiters(1)
+  clock.Advance(time.Second)
+  clock.WaitForWaiters(1)
+  clock.Advance(2 * time.Second)
+  if err := <-result; err == nil || calls != 3 {
+    t.Errorf("Expected 3 failed calls got %v, %v", calls, err)
+  }
+}
+
+func TestRetryPolicyDoGivesUpWhenDone(t *testing.T) {
+  p := &RetryPolicy{MaxAttempts: 10, Backoff: time.Hour, Clock: newFakeClock()}
+  done := make(chan struct{})
+  close(done)
+  calls := 0
+  err := p.do(done, func() error {
+    calls++
+    return errors.New("failed")
+  })
+  if err == nil || calls != 1 {
+    t.Errorf("Expected 1 failed call got %v, %v", calls, err)
+  }
+}
+
+func TestNilRetryPolicy(t *testing.T) {
+  var p *RetryPolicy
+  calls := 0
+  err := p.do(nil, func() error {
+    calls++
+    return errors.New("failed")
+  })
+  if err == nil || calls != 1 {
+    t.Errorf("Expected 1 failed call got %v, %v", calls, err)
+  }
+}
@@ -0,0 +1,98 @@
+package functional
+
+import (
+  "bytes"
+  "io"
+)
+
+const (
+  kReverseBlockSize = 4096
+)
+
+// ReadLinesReverse returns the lines of text in f as a Stream of string,
+// starting with the last line and ending with the first. size is the size
+// of f in bytes. ReadLinesReverse reads f backward in blocks, so it is
+// suited to large, append-only files where the most recent lines are
+// wanted first. Lines are split the same way as in ReadLines: they may be
+// separated by either "\n" or "\r\n", may be of any length, and the final
+// line need not end with a newline. If reading f fails, the returned Stream
+// stops and its Err method reports the error.
+func ReadLinesReverse(f io.ReaderAt, size int64) ErrStream {
+  return &reverseLineStream{r: f, pos: size}
+}
+
+type reverseLineStream struct {
+  r io.ReaderAt
+  // pos is the offset in r of the first byte of buf.
+  pos int64
+  buf []byte
+  started bool
+  done bool
+  err error
+}
+
+func (s *reverseLineStream) Next(ptr interface{}) bool {
+  p := ptr.(*string)
+  if s.done {
+    return false
+  }
+  if !s.started {
+    s.started = true
+    if !s.fill() {
+      return false
+    }
+    // A trailing newline ends the last line rather than starting a new one.
+    if s.buf[len(s.buf) - 1] == '\n' {
+      s.buf = s.buf[:len(s.buf) - 1]
+    }
+  }
+  for {
+    if i := bytes.LastIndexByte(s.buf, '\n'); i >= 0 {
+      *p = string(dropCR(s.buf[i + 1:]))
+      s.buf = s.buf[:i]
+      return true
+    }
+    if s.pos == 0 {
+      *p = string(dropCR(s.buf))
+      s.done = true
+      return true
+    }
+    if !s.fill() {
+      return false
+    }
+  }
+}
+
+func (s *reverseLineStream) Err() error {
+  return s.err
+}
+
+// fill prepends the block before buf to buf. So that long lines take linear
+// time, the block is at least as big as buf. fill returns false if there
+// is nothing left to read or if reading fails.
+func (s *reverseLineStream) fill() bool {
+  if s.pos == 0 {
+    s.done = true
+    return false
+  }
+  n := int64(kReverseBlockSize)
+  if int64(len(s.buf)) > n {
+    n = int64(len(s.buf))
+  }
+  if n > s.pos {
+    n = s.pos
+  }
+  block := make([]byte, int(n) + len(s.buf))
+  if read, err := s.r.ReadAt(block[:n], s.pos - n); int64(read) < n {
+    if err == nil || err == io.EOF {
+      err = io.ErrUnexpectedEOF
+    }
+    s.err = err
+    s.done = true
+    return false
+  }
+  copy(block[n:], s.buf)
+  s.buf = block
+  s.pos -= n
+  return true
+}
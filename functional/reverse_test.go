@@ -0,0 +1,82 @@
+package functional
+
+import (
+    "errors"
+    "fmt"
+    "strings"
+    "testing"
+)
+
+var (
+  readAtError = errors.New("error reading at.")
+)
+
+func TestReadLinesReverse(t *testing.T) {
+  inputs := []string{
+      "",
+      "\n",
+      "\n\n",
+      "a",
+      "a\n",
+      "Now is\nthe time\nfor all good men.\n",
+      "Now is\r\nthe time\r\n\r\nfor all",
+      "a\r",
+      strings.Repeat("a", 4001) + strings.Repeat("b", 4001) + "\n" + "foo",
+      "foo\n" + strings.Repeat("a", 10000) + "\r\n" + strings.Repeat("c", 5000) + "\n",
+      strings.Repeat("line\n", 3000)}
+  for _, input := range inputs {
+    var expected []string
+    AppendValues(ReadLines(strings.NewReader(input)), &expected)
+    for i := 0; i < len(expected) - i - 1; i++ {
+      expected[i], expected[len(expected) - i - 1] = expected[len(expected) - i - 1], expected[i]
+    }
+    s := ReadLinesReverse(strings.NewReader(input), int64(len(input)))
+    var results []string
+    AppendValues(s, &results)
+    if fmt.Sprintf("%q", results) != fmt.Sprintf("%q", expected) {
+      t.Errorf("For %q expected %d lines, got %d", input, len(expected), len(results))
+    }
+    if s.Err() != nil {
+      t.Errorf("Expected no error got %v", s.Err())
+    }
+    if s.Next(new(string)) {
+      t.Error("Expected Stream to stay exhausted.")
+    }
+  }
+}
+
+func TestReadLinesReverseError(t *testing.T) {
+  input := strings.Repeat("a", 5000) + "\nb\n"
+  s := ReadLinesReverse(errReaderAt{strings.NewReader(input), 100}, int64(len(input)))
+  var results []string
+  AppendValues(s, &results)
+  if output := strings.Join(results, ","); output != "b" {
+    t.Errorf("Expected 'b' got '%v'", output)
+  }
+  if s.Err() != readAtError {
+    t.Errorf("Expected readAtError got %v", s.Err())
+  }
+}
+
+func TestReadLinesReverseShortFile(t *testing.T) {
+  s := ReadLinesReverse(strings.NewReader("a\nb"), 10)
+  if s.Next(new(string)) {
+    t.Error("Expected no lines.")
+  }
+  if s.Err() == nil {
+    t.Error("Expected an error.")
+  }
+}
+
+// errReaderAt fails when reading before offset min.
+type errReaderAt struct {
+  r *strings.Reader
+  min int64
+}
+
+func (r errReaderAt) ReadAt(p []byte, off int64) (int, error) {
+  if off < r.min {
+    return 0, readAtError
+  }
+  return r.r.ReadAt(p, off)
+}
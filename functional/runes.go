@@ -0,0 +1,144 @@
+package functional
+
+import (
+  "bufio"
+  "fmt"
+  "io"
+  "strings"
+  "unicode/utf8"
+)
+
+// InvalidUTF8Policy tells a RuneStream what to do with bytes that are not
+// valid UTF-8.
+type InvalidUTF8Policy int
+
+const (
+  // ReplaceInvalid emits utf8.RuneError for each invalid byte.
+  ReplaceInvalid InvalidUTF8Policy = iota
+  // SkipInvalid drops invalid bytes.
+  SkipInvalid
+  // StopOnInvalid stops the RuneStream at the first invalid byte. Err then
+  // returns an *InvalidUTF8Error.
+  StopOnInvalid
+)
+
+// InvalidUTF8Error reports the position of a byte that is not valid UTF-8.
+type InvalidUTF8Error struct {
+  // Line is the line of the invalid byte. The first line is 1.
+  Line int
+  // Column is the column of the invalid byte. The first column is 1.
+  Column int
+}
+
+func (e *InvalidUTF8Error) Error() string {
+  return fmt.Sprintf("invalid UTF-8 at line %d, column %d", e.Line, e.Column)
+}
+
+// RuneStream is a Stream of rune that tracks the line and column of each
+// rune it emits. Columns count runes, not bytes; each invalid byte counts
+// as one column.
+type RuneStream struct {
+  r io.RuneReader
+  policy InvalidUTF8Policy
+  line int
+  column int
+  nextLine int
+  nextColumn int
+  err error
+  done bool
+}
+
+// ReadRunes returns the runes in r, which is UTF-8 encoded, as a
+// RuneStream. policy tells what to do with invalid UTF-8. If reading r
+// fails, the RuneStream stops and Err reports the error.
+func ReadRunes(r io.Reader, policy InvalidUTF8Policy) *RuneStream {
+  rr, ok := r.(io.RuneReader)
+  if !ok {
+    rr = bufio.NewReader(r)
+  }
+  return &RuneStream{r: rr, policy: policy, nextLine: 1, nextColumn: 1}
+}
+
+// StringRunes returns the runes in s as a RuneStream. policy tells what to
+// do with invalid UTF-8.
+func StringRunes(s string, policy InvalidUTF8Policy) *RuneStream {
+  return ReadRunes(strings.NewReader(s), policy)
+}
+
+// Next emits the next rune. ptr is a *rune.
+func (s *RuneStream) Next(ptr interface{}) bool {
+  p := ptr.(*rune)
+  for !s.done {
+    r, size, err := s.r.ReadRune()
+    if err != nil {
+      s.done = true
+      if err != io.EOF {
+        s.err = err
+      }
+      return false
+    }
+    line, column := s.advance(r)
+    if r == utf8.RuneError && size == 1 {
+      if s.policy == SkipInvalid {
+        continue
+      }
+      if s.policy == StopOnInvalid {
+        s.done = true
+        s.err = &InvalidUTF8Error{Line: line, Column: column}
+        return false
+      }
+    }
+    s.line, s.column = line, column
+    *p = r
+    return true
+  }
+  return false
+}
+
+// Err returns the error that stopped this RuneStream or nil if it reached
+// the end of its input normally.
+func (s *RuneStream) Err() error {
+  return s.err
+}
+
+// Line returns the line of the most recently emitted rune. The first line
+// is 1.
+func (s *RuneStream) Line() int {
+  return s.line
+}
+
+// Column returns the column of the most recently emitted rune. The first
+// column is 1.
+func (s *RuneStream) Column() int {
+  return s.column
+}
+
+func (s *RuneStream) advance(r rune) (line, column int) {
+  line, column = s.nextLine, s.nextColumn
+  if r == '\n' {
+    s.nextLine++
+    s.nextColumn = 1
+  } else {
+    s.nextColumn++
+  }
+  return
+}
+
+// RuneCollector is a Consumer of rune that collects the runes it consumes
+// into a string.
+type RuneCollector struct {
+  b strings.Builder
+}
+
+// Consume appends the runes in s, a Stream of rune, to this instance.
+func (c *RuneCollector) Consume(s Stream) {
+  var r rune
+  for s.Next(&r) {
+    c.b.WriteRune(r)
+  }
+}
+
+// String returns the runes collected so far as a string.
+func (c *RuneCollector) String() string {
+  return c.b.String()
+}
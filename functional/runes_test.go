@@ -0,0 +1,92 @@
+package functional
+
+import (
+    "fmt"
+    "strings"
+    "testing"
+    "testing/iotest"
+)
+
+func TestStringRunes(t *testing.T) {
+  s := StringRunes("héllo, 世界", ReplaceInvalid)
+  var results []rune
+  AppendValues(s, &results)
+  if output := string(results); output != "héllo, 世界" {
+    t.Errorf("Expected 'héllo, 世界' got '%v'", output)
+  }
+  if s.Err() != nil {
+    t.Errorf("Expected no error got %v", s.Err())
+  }
+}
+
+func TestReadRunesPositions(t *testing.T) {
+  s := ReadRunes(iotest.OneByteReader(strings.NewReader("ab\n世\nc")), ReplaceInvalid)
+  var positions []string
+  var r rune
+  for s.Next(&r) {
+    positions = append(positions, fmt.Sprintf("%c:%d:%d", r, s.Line(), s.Column()))
+  }
+  expected := "a:1:1 b:1:2 \n:1:3 世:2:1 \n:2:2 c:3:1"
+  if output := strings.Join(positions, " "); output != expected {
+    t.Errorf("Expected '%v' got '%v'", expected, output)
+  }
+}
+
+func TestRunesReplaceInvalid(t *testing.T) {
+  var results []rune
+  AppendValues(StringRunes("a\xffb\xef\xbf\xbd", ReplaceInvalid), &results)
+  if output := fmt.Sprintf("%q", results); output != "['a' '�' 'b' '�']" {
+    t.Errorf("Expected ['a' '�' 'b' '�'] got %v", output)
+  }
+}
+
+func TestRunesSkipInvalid(t *testing.T) {
+  s := StringRunes("a\xff\xfeb\xef\xbf\xbd", SkipInvalid)
+  var results []rune
+  AppendValues(s, &results)
+  if output := fmt.Sprintf("%q", results); output != "['a' 'b' '�']" {
+    t.Errorf("Expected ['a' 'b' '�'] got %v", output)
+  }
+  if s.Column() != 5 {
+    t.Errorf("Expected column 5 got %v", s.Column())
+  }
+}
+
+func TestRunesStopOnInvalid(t *testing.T) {
+  s := StringRunes("ab\nc\xffd", StopOnInvalid)
+  var results []rune
+  AppendValues(s, &results)
+  if output := string(results); output != "ab\nc" {
+    t.Errorf("Expected 'ab\\nc' got '%v'", output)
+  }
+  err, ok := s.Err().(*InvalidUTF8Error)
+  if !ok || err.Line != 2 || err.Column != 2 {
+    t.Errorf("Expected invalid UTF-8 at line 2, column 2 got %v", s.Err())
+  }
+  if s.Next(new(rune)) {
+    t.Error("Stream should stay stopped.")
+  }
+}
+
+func TestReadRunesError(t *testing.T) {
+  s := ReadRunes(iotest.TimeoutReader(iotest.OneByteReader(strings.NewReader("abc"))), ReplaceInvalid)
+  var results []rune
+  AppendValues(s, &results)
+  if output := string(results); output != "a" {
+    t.Errorf("Expected 'a' got '%v'", output)
+  }
+  if s.Err() != iotest.ErrTimeout {
+    t.Errorf("Expected iotest.ErrTimeout got %v", s.Err())
+  }
+}
+
+func TestRuneCollector(t *testing.T) {
+  var c RuneCollector
+  isVowel := NewFilterer(func(ptr interface{}) bool {
+    return strings.ContainsRune("aeiou", *ptr.(*rune))
+  })
+  c.Consume(Filter(isVowel, StringRunes("functional", ReplaceInvalid)))
+  if output := c.String(); output != "uioa" {
+    t.Errorf("Expected 'uioa' got '%v'", output)
+  }
+}
@@ -0,0 +1,64 @@
+package functional
+
+import (
+  "bufio"
+  "io"
+  "math"
+)
+
+// NewStreamReader returns an io.Reader that reads the bytes of the values in
+// s, a Stream of T. encode converts each T value to bytes; it takes a *T and
+// may return a slice that it reuses with each call. ptr is a *T that
+// receives the values from s.
+func NewStreamReader(s Stream, ptr interface{}, encode func(ptr interface{}) []byte) io.Reader {
+  return &streamReader{s: s, ptr: ptr, encode: encode}
+}
+
+// NewWriterGenerator returns an io.WriteCloser and a Generator of string
+// that emits the tokens written to the io.WriteCloser. split breaks the
+// written bytes into tokens; there is no limit on token length. Closing the
+// io.WriteCloser ends the Generator. Writes block until the Generator
+// consumes the tokens, so writing and consuming must happen on different
+// goroutines. If the Generator is closed first, or if split returns an
+// error, subsequent writes return an error.
+func NewWriterGenerator(split bufio.SplitFunc) (io.WriteCloser, Generator) {
+  r, w := io.Pipe()
+  g := NewGenerator(func(e Emitter) {
+    scanner := bufio.NewScanner(r)
+    scanner.Buffer(nil, math.MaxInt)
+    scanner.Split(split)
+    for ptr := e.EmitPtr(); ptr != nil && scanner.Scan(); ptr = e.EmitPtr() {
+      *ptr.(*string) = scanner.Text()
+    }
+    if err := scanner.Err(); err != nil {
+      r.CloseWithError(err)
+    } else {
+      r.Close()
+    }
+  })
+  return w, g
+}
+
+type streamReader struct {
+  s Stream
+  ptr interface{}
+  encode func(ptr interface{}) []byte
+  buf []byte
+  done bool
+}
+
+func (r *streamReader) Read(p []byte) (n int, err error) {
+  if len(p) == 0 {
+    return 0, nil
+  }
+  for len(r.buf) == 0 {
+    if r.done || !r.s.Next(r.ptr) {
+      r.done = true
+      return 0, io.EOF
+    }
+    r.buf = r.encode(r.ptr)
+  }
+  n = copy(p, r.buf)
+  r.buf = r.buf[n:]
+  return
+}
@@ -0,0 +1,98 @@
+package functional
+
+import (
+    "bufio"
+    "crypto/sha256"
+    "fmt"
+    "io"
+    "strings"
+    "testing"
+    "testing/iotest"
+)
+
+func TestStreamReader(t *testing.T) {
+  s := NewStreamFromValues([]string{"Now is", "", "the time"})
+  r := NewStreamReader(s, new(string), appendNewLine)
+  b, err := io.ReadAll(iotest.OneByteReader(r))
+  if err != nil {
+    t.Fatalf("Got error %v", err)
+  }
+  if output := string(b); output != "Now is\n\nthe time\n" {
+    t.Errorf("Expected 'Now is\\n\\nthe time\\n' got '%v'", output)
+  }
+}
+
+func TestStreamReaderHash(t *testing.T) {
+  h := sha256.New()
+  s := NewStreamFromValues([]string{"a", "b"})
+  if _, err := io.Copy(h, NewStreamReader(s, new(string), appendNewLine)); err != nil {
+    t.Fatalf("Got error %v", err)
+  }
+  expected := sha256.Sum256([]byte("a\nb\n"))
+  if output := fmt.Sprintf("%x", h.Sum(nil)); output != fmt.Sprintf("%x", expected) {
+    t.Errorf("Expected %x got %v", expected, output)
+  }
+}
+
+func TestStreamReaderEmpty(t *testing.T) {
+  r := NewStreamReader(NilStream(), new(string), appendNewLine)
+  if n, err := r.Read(make([]byte, 10)); n != 0 || err != io.EOF {
+    t.Errorf("Expected 0, EOF got %v, %v", n, err)
+  }
+  if n, err := r.Read(make([]byte, 10)); n != 0 || err != io.EOF {
+    t.Errorf("Expected 0, EOF got %v, %v", n, err)
+  }
+}
+
+func TestWriterGenerator(t *testing.T) {
+  w, g := NewWriterGenerator(bufio.ScanWords)
+  go func() {
+    io.WriteString(w, "Now is the ti")
+    io.WriteString(w, "me for all\n good men")
+    w.Close()
+  }()
+  var results []string
+  AppendValues(g, &results)
+  if output := strings.Join(results, ","); output != "Now,is,the,time,for,all,good,men" {
+    t.Errorf("Expected 'Now,is,the,time,for,all,good,men' got '%v'", output)
+  }
+  g.Close()
+}
+
+func TestWriterGeneratorLongToken(t *testing.T) {
+  str := strings.Repeat("a", 100000)
+  w, g := NewWriterGenerator(bufio.ScanLines)
+  go func() {
+    io.WriteString(w, str + "\nfoo")
+    w.Close()
+  }()
+  var results []string
+  AppendValues(g, &results)
+  if len(results) != 2 || results[0] != str || results[1] != "foo" {
+    t.Error("Long token failed.")
+  }
+}
+
+func TestWriterGeneratorClosedEarly(t *testing.T) {
+  w, g := NewWriterGenerator(bufio.ScanLines)
+  errCh := make(chan error)
+  go func() {
+    var err error
+    for err == nil {
+      _, err = io.WriteString(w, "line\n")
+    }
+    errCh <- err
+  }()
+  var result string
+  if !g.Next(&result) || result != "line" {
+    t.Errorf("Expected 'line' got '%v'", result)
+  }
+  g.Close()
+  if err := <-errCh; err != io.ErrClosedPipe {
+    t.Errorf("Expected io.ErrClosedPipe got %v", err)
+  }
+}
+
+func appendNewLine(ptr interface{}) []byte {
+  return []byte(*ptr.(*string) + "\n")
+}
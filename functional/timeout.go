@@ -0,0 +1,115 @@
+package functional
+
+import (
+  "errors"
+  "sync"
+  "time"
+)
+
+// ErrTimeout is the error a Generator from WithTimeout reports when a value
+// does not arrive in time.
+var ErrTimeout = errors.New("functional: timed out waiting for value")
+
+// TimeoutOptions contains optional settings for WithTimeout.
+type TimeoutOptions struct {
+  // Copier is a Copier of T that copies values to the caller. If nil,
+  // regular assignment is used.
+  Copier Copier
+  // If true, the source Generator is closed on a separate goroutine as
+  // soon as a value times out even though its Next call is still in
+  // progress.
+  CloseOnTimeout bool
+}
+
+// WithTimeout returns a Generator of T that emits the values of g, a
+// Generator of T, giving up if a value does not arrive within perValue as
+// measured by clock. When a value times out, Next returns false and the
+// returned Generator's Err method, from ErrStream, reports ErrTimeout; the
+// returned Generator emits no more values after that. Otherwise, if g is an
+// ErrStream, Err reports its error once its values run out. Since g keeps
+// reading on a separate goroutine after a value times out, g reads into a
+// value that WithTimeout creates with c, a Creater of T. Closing the
+// returned Generator closes g unless it is already closed. Close does not
+// wait for a Next call on g that timed out, so g must allow concurrent
+// Close, as described for Generator, if a value may time out. If clock is
+// nil, SystemClock is used. opts may be nil.
+func WithTimeout(
+    g Generator, perValue time.Duration, clock Clock, c Creater,
+    opts *TimeoutOptions) Generator {
+  if opts == nil {
+    opts = &TimeoutOptions{}
+  }
+  copier := opts.Copier
+  if copier == nil {
+    copier = assignCopier
+  }
+  return &timeoutGenerator{
+      g: g,
+      perValue: perValue,
+      clock: clockOrDefault(clock),
+      copier: copier,
+      closeOnTimeout: opts.CloseOnTimeout,
+      value: c(),
+      results: make(chan bool, 1)}
+}
+
+type timeoutGenerator struct {
+  g Generator
+  perValue time.Duration
+  clock Clock
+  copier Copier
+  closeOnTimeout bool
+  // value is what g reads into.
+  value interface{}
+  // results receives the result of each Next call on g.
+  results chan bool
+  // done is true once g is exhausted or a value times out.
+  done bool
+  err error
+  closeOnce sync.Once
+  closeErr error
+}
+
+func (t *timeoutGenerator) Next(ptr interface{}) bool {
+  if t.done {
+    return false
+  }
+  go func() {
+    t.results <- t.g.Next(t.value)
+  }()
+  select {
+  case ok := <-t.results:
+    if !ok {
+      t.done = true
+      if es, isErrStream := t.g.(ErrStream); isErrStream {
+        t.err = es.Err()
+      }
+      return false
+    }
+    t.copier(t.value, ptr)
+    return true
+  case <-t.clock.After(t.perValue):
+    t.done = true
+    t.err = ErrTimeout
+    if t.closeOnTimeout {
+      go t.closeSource()
+    }
+    return false
+  }
+}
+
+func (t *timeoutGenerator) Err() error {
+  return t.err
+}
+
+func (t *timeoutGenerator) Close() error {
+  t.done = true
+  t.closeSource()
+  return t.closeErr
+}
+
+func (t *timeoutGenerator) closeSource() {
+  t.closeOnce.Do(func() {
+    t.closeErr = t.g.Close()
+  })
+}
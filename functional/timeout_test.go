@@ -0,0 +1,195 @@
+package functional
+
+import (
+    "errors"
+    "fmt"
+    "strings"
+    "testing"
+    "testing/iotest"
+    "time"
+)
+
+func TestWithTimeout(t *testing.T) {
+  source := &closeCountingGenerator{Stream: xrange(0, 5)}
+  g := WithTimeout(source, time.Hour, nil, func() interface{} { return new(int) }, nil)
+  var results []int
+  AppendValues(g, &results)
+  if output := fmt.Sprintf("%v", results); output != "[0 1 2 3 4]" {
+    t.Errorf("Expected [0 1 2 3 4] got %v", output)
+  }
+  if err := g.(ErrStream).Err(); err != nil {
+    t.Errorf("Expected no error got %v", err)
+  }
+  if err := g.Close(); err != nil {
+    t.Errorf("Expected no error got %v", err)
+  }
+  g.Close()
+  if source.closed != 1 {
+    t.Errorf("Expected source closed once got %v", source.closed)
+  }
+}
+
+func TestWithTimeoutTimesOut(t *testing.T) {
+  clock := newFakeClock()
+  slow := &gatedGenerator{gate: make(chan struct{})}
+  g := WithTimeout(slow, time.Second, clock, func() interface{} { return new(int) }, nil)
+  result := make(chan bool)
+  go func() {
+    result <- g.Next(new(int))
+  }()
+  clock.WaitForWaiters(1)
+  clock.Advance(time.Second)
+  if <-result {
+    t.Error("Expected Next to time out.")
+  }
+  if err := g.(ErrStream).Err(); err != ErrTimeout {
+    t.Errorf("Expected ErrTimeout got %v", err)
+  }
+  if slow.closed {
+    t.Error("Expected source left open.")
+  }
+  close(slow.gate)
+  if g.Next(new(int)) {
+    t.Error("Expected no values after a timeout.")
+  }
+  g.Close()
+  if !slow.closed {
+    t.Error("Expected Close to close source.")
+  }
+}
+
+func TestWithTimeoutCloseOnTimeout(t *testing.T) {
+  clock := newFakeClock()
+  source := &blockingGenerator{unblock: make(chan struct{})}
+  g := WithTimeout(
+      source,
+      time.Second,
+      clock,
+      func() interface{} { return new(int) },
+      &TimeoutOptions{CloseOnTimeout: true})
+  result := make(chan bool)
+  go func() {
+    result <- g.Next(new(int))
+  }()
+  clock.WaitForWaiters(1)
+  clock.Advance(time.Second)
+  if <-result {
+    t.Error("Expected Next to time out.")
+  }
+  // The timeout closes source on a separate goroutine.
+  <-source.unblock
+  g.Close()
+  if source.closed != 1 {
+    t.Errorf("Expected source closed once got %v", source.closed)
+  }
+}
+
+func TestWithTimeoutWaitingGenerator(t *testing.T) {
+  clock := newFakeClock()
+  values := make(chan int)
+  asked := make(chan struct{}, 10)
+  g := WithTimeout(
+      chanEmitter(values, asked),
+      time.Second,
+      clock,
+      func() interface{} { return new(int) },
+      nil)
+  defer close(values)
+  result := make(chan bool)
+  go func() {
+    result <- g.Next(new(int))
+  }()
+  <-asked
+  clock.WaitForWaiters(1)
+  clock.Advance(time.Second)
+  if <-result {
+    t.Error("Expected Next to time out.")
+  }
+  if err := g.Close(); err != nil {
+    t.Errorf("Expected no error got %v", err)
+  }
+}
+
+func TestWithTimeoutCloseOnTimeoutWaitingGenerator(t *testing.T) {
+  clock := newFakeClock()
+  values := make(chan int)
+  asked := make(chan struct{}, 10)
+  source := chanEmitter(values, asked)
+  g := WithTimeout(
+      source,
+      time.Second,
+      clock,
+      func() interface{} { return new(int) },
+      &TimeoutOptions{CloseOnTimeout: true})
+  defer close(values)
+  result := make(chan bool)
+  go func() {
+    result <- g.Next(new(int))
+  }()
+  <-asked
+  clock.WaitForWaiters(1)
+  clock.Advance(time.Second)
+  if <-result {
+    t.Error("Expected Next to time out.")
+  }
+  if err := g.Close(); err != nil {
+    t.Errorf("Expected no error got %v", err)
+  }
+  if source.Next(new(int)) {
+    t.Error("Expected timeout to close source.")
+  }
+}
+
+func TestWithTimeoutCopier(t *testing.T) {
+  s := NewStreamFromValues([][]int{{1, 2}, {3}})
+  copier := func(src, dest interface{}) {
+    *dest.(*[]int) = append([]int(nil), *src.(*[]int)...)
+  }
+  g := WithTimeout(
+      StreamToGenerator(s, nopCloser{}),
+      time.Hour,
+      nil,
+      func() interface{} { return new([]int) },
+      &TimeoutOptions{Copier: copier})
+  var results [][]int
+  AppendValues(g, &results)
+  if output := fmt.Sprintf("%v", results); output != "[[1 2] [3]]" {
+    t.Errorf("Expected [[1 2] [3]] got %v", output)
+  }
+}
+
+func TestWithTimeoutError(t *testing.T) {
+  bad := ReadLines(iotest.TimeoutReader(iotest.OneByteReader(strings.NewReader("a\nb\n"))))
+  g := WithTimeout(
+      errStreamGenerator{bad},
+      time.Hour,
+      nil,
+      func() interface{} { return new(string) },
+      nil)
+  defer g.Close()
+  var results []string
+  AppendValues(g, &results)
+  if output := strings.Join(results, ","); output != "a" {
+    t.Errorf("Expected 'a' got '%v'", output)
+  }
+  if err := g.(ErrStream).Err(); !errors.Is(err, iotest.ErrTimeout) {
+    t.Errorf("Expected timeout error got %v", err)
+  }
+}
+
+// blockingGenerator blocks in Next until it is closed.
+type blockingGenerator struct {
+  unblock chan struct{}
+  closed int
+}
+
+func (g *blockingGenerator) Next(ptr interface{}) bool {
+  <-g.unblock
+  return false
+}
+
+func (g *blockingGenerator) Close() error {
+  g.closed++
+  close(g.unblock)
+  return nil
+}
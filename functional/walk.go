@@ -0,0 +1,151 @@
+package functional
+
+import (
+  "io/fs"
+  "path"
+  "path/filepath"
+  "strings"
+)
+
+// FileEntry is a file or directory that WalkDir emits.
+type FileEntry struct {
+  // Path is the path of the file. It starts with the root passed to WalkDir.
+  Path string
+  // Entry describes the file. Entry is nil if root could not be read.
+  Entry fs.DirEntry
+  // Err is the error encountered reading the file or directory at Path,
+  // or nil if there was no error.
+  Err error
+}
+
+// WalkOptions are the options for WalkDir.
+type WalkOptions struct {
+  // FS is the file system to walk. If nil, WalkDir walks the operating
+  // system's file system, and paths use the operating system's separator.
+  FS fs.FS
+  // Include contains glob patterns as understood by path.Match. If Include
+  // is non-empty, WalkDir emits only the entries whose base name matches
+  // one of the patterns. Directories are walked whether or not they match.
+  Include []string
+  // Exclude contains glob patterns as understood by path.Match. WalkDir
+  // emits no entry whose base name matches one of the patterns and does
+  // not walk directories whose base name matches.
+  Exclude []string
+  // MaxDepth is the maximum depth to walk. root has depth 0; its children
+  // have depth 1. If MaxDepth is 0, there is no limit.
+  MaxDepth int
+}
+
+// WalkDir returns a Generator of FileEntry that emits the files and
+// directories in the tree rooted at root in lexical order, directories
+// before their contents. WalkDir walks the tree lazily, reading
+// directories only as Next is called, so closing the returned Generator
+// early, say after TakeWhile or Slice stop reading, stops the walk. Errors
+// reading files or directories are emitted as FileEntry values with Err
+// set; after an error reading a directory, the walk continues with the
+// next directory. The caller can steer the walk by passing fs.SkipDir to
+// Send after receiving a directory to skip its contents, or fs.SkipAll to
+// end the walk. opts may be nil. If a pattern in opts is malformed, the
+// returned Generator emits a single FileEntry with Err set to
+// path.ErrBadPattern.
+func WalkDir(root string, opts *WalkOptions) SendGenerator {
+  if opts == nil {
+    opts = &WalkOptions{}
+  }
+  if err := checkPatterns(opts.Include, opts.Exclude); err != nil {
+    return NewSendGenerator(func(e SendEmitter) {
+      if ptr := e.EmitPtr(); ptr != nil {
+        *ptr.(*FileEntry) = FileEntry{Path: root, Err: err}
+        e.EmitPtr()
+      }
+    })
+  }
+  w := &walker{opts: opts, root: root, cleanRoot: path.Clean(root), sep: "/"}
+  if opts.FS == nil {
+    w.cleanRoot = filepath.Clean(root)
+    w.sep = string(filepath.Separator)
+  }
+  return NewSendGenerator(func(e SendEmitter) {
+    ptr := e.EmitPtr()
+    if ptr == nil {
+      return
+    }
+    walkFn := func(p string, d fs.DirEntry, err error) error {
+      emit, result := w.visit(p, d, err)
+      if emit {
+        *ptr.(*FileEntry) = FileEntry{Path: p, Entry: d, Err: err}
+        var reply interface{}
+        if ptr, reply = e.EmitPtrRecv(); ptr == nil {
+          return fs.SkipAll
+        }
+        if reply == fs.SkipAll || (reply == fs.SkipDir && d != nil && d.IsDir()) {
+          return reply.(error)
+        }
+      }
+      return result
+    }
+    if opts.FS == nil {
+      filepath.WalkDir(root, walkFn)
+    } else {
+      fs.WalkDir(opts.FS, root, walkFn)
+    }
+  })
+}
+
+type walker struct {
+  opts *WalkOptions
+  root string
+  cleanRoot string
+  sep string
+}
+
+// visit returns whether to emit an entry and what the fs.WalkDirFunc
+// should return for it.
+func (w *walker) visit(p string, d fs.DirEntry, err error) (emit bool, result error) {
+  if err != nil {
+    return true, nil
+  }
+  isDir := d.IsDir()
+  if matchAny(w.opts.Exclude, d.Name()) {
+    if isDir {
+      return false, fs.SkipDir
+    }
+    return false, nil
+  }
+  if isDir && w.opts.MaxDepth > 0 && w.depth(p) >= w.opts.MaxDepth {
+    result = fs.SkipDir
+  }
+  emit = len(w.opts.Include) == 0 || matchAny(w.opts.Include, d.Name())
+  return
+}
+
+func (w *walker) depth(p string) int {
+  if p == w.root || p == w.cleanRoot {
+    return 0
+  }
+  rel := p
+  if w.cleanRoot != "." {
+    rel = strings.TrimPrefix(strings.TrimPrefix(p, w.cleanRoot), w.sep)
+  }
+  return strings.Count(rel, w.sep) + 1
+}
+
+func matchAny(patterns []string, name string) bool {
+  for _, pattern := range patterns {
+    if matched, _ := path.Match(pattern, name); matched {
+      return true
+    }
+  }
+  return false
+}
+
+func checkPatterns(patternLists ...[]string) error {
+  for _, patterns := range patternLists {
+    for _, pattern := range patterns {
+      if _, err := path.Match(pattern, ""); err != nil {
+        return err
+      }
+    }
+  }
+  return nil
+}
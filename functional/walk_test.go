@@ -0,0 +1,169 @@
+package functional
+
+import (
+    "io/fs"
+    "os"
+    "path/filepath"
+    "strings"
+    "testing"
+    "testing/fstest"
+)
+
+func TestWalkDir(t *testing.T) {
+  g := WalkDir(".", &WalkOptions{FS: walkTestFS()})
+  if output := walkPaths(g); output != ".,a,a/b.txt,a/c,a/c/d.go,a/c/e.txt,f.go" {
+    t.Errorf("Expected '.,a,a/b.txt,a/c,a/c/d.go,a/c/e.txt,f.go' got '%v'", output)
+  }
+}
+
+func TestWalkDirSubtree(t *testing.T) {
+  g := WalkDir("a/c", &WalkOptions{FS: walkTestFS(), MaxDepth: 1})
+  if output := walkPaths(g); output != "a/c,a/c/d.go,a/c/e.txt" {
+    t.Errorf("Expected 'a/c,a/c/d.go,a/c/e.txt' got '%v'", output)
+  }
+}
+
+func TestWalkDirIncludeExclude(t *testing.T) {
+  g := WalkDir(".", &WalkOptions{FS: walkTestFS(), Include: []string{"*.go", "*.txt"}, Exclude: []string{"c"}})
+  if output := walkPaths(g); output != "a/b.txt,f.go" {
+    t.Errorf("Expected 'a/b.txt,f.go' got '%v'", output)
+  }
+}
+
+func TestWalkDirMaxDepth(t *testing.T) {
+  g := WalkDir(".", &WalkOptions{FS: walkTestFS(), MaxDepth: 2})
+  if output := walkPaths(g); output != ".,a,a/b.txt,a/c,f.go" {
+    t.Errorf("Expected '.,a,a/b.txt,a/c,f.go' got '%v'", output)
+  }
+}
+
+func TestWalkDirBadPattern(t *testing.T) {
+  g := WalkDir(".", &WalkOptions{FS: walkTestFS(), Include: []string{"["}})
+  var entry FileEntry
+  if !g.Next(&entry) || entry.Err == nil {
+    t.Error("Expected error entry for bad pattern.")
+  }
+  if g.Next(&entry) {
+    t.Error("Expected only one entry.")
+  }
+  g.Close()
+}
+
+func TestWalkDirMissingRoot(t *testing.T) {
+  g := WalkDir("missing", &WalkOptions{FS: walkTestFS()})
+  var results []FileEntry
+  AppendValues(g, &results)
+  if len(results) != 1 || results[0].Err == nil || results[0].Entry != nil {
+    t.Errorf("Expected one error entry got %v", results)
+  }
+}
+
+func TestWalkDirLazy(t *testing.T) {
+  mapFS := walkTestFS()
+  mapFS["g/h.txt"] = &fstest.MapFile{}
+  fsys := &countingFS{FS: mapFS}
+  g := WalkDir(".", &WalkOptions{FS: fsys})
+  if fsys.opened != 0 {
+    t.Error("WalkDir should not read anything before Next is called.")
+  }
+  isNotGoFile := NewFilterer(func(ptr interface{}) bool {
+    return !strings.HasSuffix(ptr.(*FileEntry).Path, ".go")
+  })
+  var results []FileEntry
+  AppendValues(TakeWhile(isNotGoFile, g), &results)
+  g.Close()
+  if len(results) != 4 {
+    t.Errorf("Expected 4 entries got %v", len(results))
+  }
+  if fsys.opened != 3 {
+    t.Errorf("Expected 3 directories read got %v", fsys.opened)
+  }
+}
+
+func TestWalkDirOS(t *testing.T) {
+  root := t.TempDir()
+  os.MkdirAll(filepath.Join(root, "x", "y"), 0755)
+  os.WriteFile(filepath.Join(root, "x", "y", "z.txt"), nil, 0644)
+  os.WriteFile(filepath.Join(root, "w.txt"), nil, 0644)
+  g := WalkDir(root, &WalkOptions{MaxDepth: 2})
+  var paths []string
+  var entry FileEntry
+  for g.Next(&entry) {
+    rel, _ := filepath.Rel(root, entry.Path)
+    paths = append(paths, filepath.ToSlash(rel))
+  }
+  if output := strings.Join(paths, ","); output != ".,w.txt,x,x/y" {
+    t.Errorf("Expected '.,w.txt,x,x/y' got '%v'", output)
+  }
+}
+
+func TestWalkDirSkipDir(t *testing.T) {
+  g := WalkDir(".", &WalkOptions{FS: walkTestFS()})
+  defer g.Close()
+  var paths []string
+  var entry FileEntry
+  var reply interface{}
+  for g.Send(&entry, reply) {
+    paths = append(paths, entry.Path)
+    reply = nil
+    if entry.Path == "a/c" || entry.Path == "a/b.txt" {
+      reply = fs.SkipDir
+    }
+  }
+  if output := strings.Join(paths, ","); output != ".,a,a/b.txt,a/c,f.go" {
+    t.Errorf("Expected '.,a,a/b.txt,a/c,f.go' got '%v'", output)
+  }
+}
+
+func TestWalkDirSkipAll(t *testing.T) {
+  g := WalkDir(".", &WalkOptions{FS: walkTestFS()})
+  defer g.Close()
+  var paths []string
+  var entry FileEntry
+  var reply interface{}
+  for g.Send(&entry, reply) {
+    paths = append(paths, entry.Path)
+    if entry.Path == "a/b.txt" {
+      reply = fs.SkipAll
+    }
+  }
+  if output := strings.Join(paths, ","); output != ".,a,a/b.txt" {
+    t.Errorf("Expected '.,a,a/b.txt' got '%v'", output)
+  }
+}
+
+func walkTestFS() fstest.MapFS {
+  return fstest.MapFS{
+      "a/b.txt": {},
+      "a/c/d.go": {},
+      "a/c/e.txt": {},
+      "f.go": {}}
+}
+
+func walkPaths(g Generator) string {
+  var paths []string
+  var entry FileEntry
+  for g.Next(&entry) {
+    paths = append(paths, entry.Path)
+  }
+  g.Close()
+  return strings.Join(paths, ",")
+}
+
+// countingFS counts how many directories are read.
+type countingFS struct {
+  fs.FS
+  opened int
+}
+
+func (c *countingFS) ReadDir(name string) ([]fs.DirEntry, error) {
+  c.opened++
+  return fs.ReadDir(c.FS, name)
+}
+
+type nopCloser struct {
+}
+
+func (c nopCloser) Close() error {
+  return nil
+}